@@ -0,0 +1,46 @@
+/*
+ * Copyright 2020 bigpigeon. All rights reserved.
+ * Use of this source code is governed by a MIT style
+ * license that can be found in the LICENSE file.
+ *
+ */
+
+package main
+
+import (
+	"os"
+)
+
+// command is the sub command of tagfmt, e.g tagfmt lsp
+type command struct {
+	Usage string
+	Run   func(args []string) error
+}
+
+func commands() map[string]command {
+	return map[string]command{
+		"compat": {"tagfmt compat [-keys json] [-format text|json] <old-rev> <new-rev> [packages]\n\treport the breaking changes of serialized names between two git revisions", compatMain},
+		"ddl":    {"tagfmt [flags] ddl [-dir migrations] [-keys gorm,db] [-check] [-w|-l|-d] [path ...]\n\tfill or check the gorm/db tags from the columns of CREATE TABLE statements", ddlMain},
+		"freeze": {"tagfmt [flags] freeze [-keys json] [-w|-l|-d] [path ...]\n\twrite the implicit serialized names of fields into tags", freezeMain},
+		"init":   {"tagfmt [flags] init [-force] [dir]\n\twrite the " + configName + " config reproducing the tag style of existing code", initMain},
+		"lsp":    {"tagfmt [flags] lsp\n\tstart language server over stdio, flags are used as the format options", lspMain},
+		"query":  {"tagfmt [flags] query [-format text|json] <predicate> [path ...]\n\tprint the fields match the predicate e.g 'has(json) && !option(json, \"omitempty\")'", queryMain},
+		"stats":  {"tagfmt [flags] stats [-format text|json] [packages]\n\treport the usage and naming conventions of tag keys", statsMain},
+		"vet":    {"tagfmt vet [-keys json,yaml,bson] [packages]\n\treport the serialized name collisions and shadowed fields", vetMain},
+	}
+}
+
+// lookupCommand returns the sub command if the first arg is a command name and not an existing path
+func lookupCommand(args []string) (command, bool) {
+	if len(args) == 0 {
+		return command{}, false
+	}
+	cmd, ok := commands()[args[0]]
+	if !ok {
+		return command{}, false
+	}
+	if _, err := os.Stat(args[0]); err == nil {
+		return command{}, false
+	}
+	return cmd, true
+}
@@ -0,0 +1,319 @@
+/*
+ * Copyright 2020 bigpigeon. All rights reserved.
+ * Use of this source code is governed by a MIT style
+ * license that can be found in the LICENSE file.
+ *
+ */
+
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"go/ast"
+	"go/parser"
+	"go/token"
+	"go/types"
+	"io"
+	"os"
+	"path"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// compatChange is a breaking change of serialized struct between two revisions
+type compatChange struct {
+	Package string `json:"package"`
+	Struct  string `json:"struct"`
+	Key     string `json:"key"`
+	Field   string `json:"field"`
+	Kind    string `json:"kind"`
+	Message string `json:"message"`
+}
+
+func (c compatChange) String() string {
+	return c.Package + ": " + c.Struct + ": " + c.Message
+}
+
+// revisionPackages loads the packages of paths at git revision ref,
+// the key of result is the package directory relative to repository root
+func revisionPackages(fs *token.FileSet, top, ref string, paths []string) (map[string]*typedPackage, error) {
+	wd, err := os.Getwd()
+	if err != nil {
+		return nil, err
+	}
+	// git prints the real path of top level
+	if real, err := filepath.EvalSymlinks(wd); err == nil {
+		wd = real
+	}
+	dirFiles := map[string][]string{}
+	for _, p := range paths {
+		recursive := p == "..." || strings.HasSuffix(p, "/...")
+		root := expandPath(p)
+		if !filepath.IsAbs(root) {
+			root = filepath.Join(wd, root)
+		}
+		rel, err := filepath.Rel(top, root)
+		if err != nil {
+			return nil, err
+		}
+		rel = filepath.ToSlash(rel)
+		data, err := gitOutput(top, nil, "ls-tree", "-r", "--name-only", "--full-name", ref, "--", rel)
+		if err != nil {
+			return nil, err
+		}
+		for _, name := range strings.Split(strings.TrimSpace(string(data)), "\n") {
+			if !strings.HasSuffix(name, ".go") || strings.HasSuffix(name, "_test.go") {
+				continue
+			}
+			dir := path.Dir(name)
+			if dir != rel && (!recursive || skippedPackageDir(strings.TrimPrefix(dir, rel+"/"))) {
+				continue
+			}
+			dirFiles[dir] = append(dirFiles[dir], name)
+		}
+	}
+	pkgs := map[string]*typedPackage{}
+	for dir, names := range dirFiles {
+		var files []*ast.File
+		for _, name := range names {
+			src, err := gitOutput(top, nil, "show", ref+":"+name)
+			if err != nil {
+				return nil, err
+			}
+			f, err := parser.ParseFile(fs, name, src, parser.ParseComments)
+			if err != nil {
+				return nil, err
+			}
+			// the files of other package e.g generator with build tag ignore
+			if len(files) != 0 && f.Name.Name != files[0].Name.Name {
+				continue
+			}
+			files = append(files, f)
+		}
+		pkgs[dir] = checkPackage(fs, dir, files)
+	}
+	return pkgs, nil
+}
+
+// skippedPackageDir reports whether the relative directory is ignored by '/...' pattern
+func skippedPackageDir(dir string) bool {
+	for _, name := range strings.Split(dir, "/") {
+		if strings.HasPrefix(name, ".") || strings.HasPrefix(name, "_") || name == "vendor" || name == "testdata" {
+			return true
+		}
+	}
+	return false
+}
+
+// exportedStructs returns the exported struct types of package by name
+func (p *typedPackage) exportedStructs() map[string]*types.Struct {
+	result := map[string]*types.Struct{}
+	for _, obj := range p.namedStructs() {
+		if obj.Exported() {
+			result[obj.Name()] = obj.Type().Underlying().(*types.Struct)
+		}
+	}
+	return result
+}
+
+// compareStructs returns the breaking changes of serialized names from old struct to new struct
+func compareStructs(e wireEncoder, oldSt, newSt *types.Struct) []compatChange {
+	newFields := e.fields(newSt)
+	oldVisible, newVisible := e.visible(e.fields(oldSt)), e.visible(newFields)
+	var names []string
+	for name := range oldVisible {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	var changes []compatChange
+	add := func(f wireField, kind, msg string) {
+		changes = append(changes, compatChange{Key: e.key, Field: f.path(), Kind: kind, Message: e.key + ": " + msg})
+	}
+	for _, name := range names {
+		oldField := oldVisible[name]
+		quoted := strconv.Quote(name)
+		if newField, ok := newVisible[name]; ok {
+			oldOmit, newOmit := hasOption(oldField.Value, "omitempty"), hasOption(newField.Value, "omitempty")
+			if !oldOmit && newOmit {
+				add(oldField, "omitempty_added", "omitempty is added to key "+quoted)
+			} else if oldOmit && !newOmit {
+				add(oldField, "omitempty_removed", "omitempty is removed from key "+quoted)
+			}
+			oldType, newType := typeString(oldField.Var.Type()), typeString(newField.Var.Type())
+			if oldType != newType {
+				add(oldField, "type_changed", "type of key "+quoted+" is changed from "+oldType+" to "+newType)
+			}
+			continue
+		}
+		if newField, ok := lookupWireField(newFields, oldField.Path); ok {
+			if visible, ok := newVisible[newField.Name]; ok && visible.path() == newField.path() {
+				add(oldField, "renamed_key", "key "+quoted+" is renamed to "+strconv.Quote(newField.Name))
+			} else {
+				add(oldField, "removed_key", "key "+quoted+" is hidden by the other fields named "+strconv.Quote(newField.Name))
+			}
+			continue
+		}
+		if tag, ok := lookupFieldTag(newSt, oldField.Path, e.key); ok && tag == "-" {
+			add(oldField, "ignored", "key "+quoted+" is ignored by \"-\"")
+			continue
+		}
+		add(oldField, "removed_key", "key "+quoted+" is removed")
+	}
+	return changes
+}
+
+// comparePackages returns the breaking changes of all exported structs in old packages
+func comparePackages(oldPkgs, newPkgs map[string]*typedPackage, keys []string) []compatChange {
+	var dirs []string
+	for dir := range oldPkgs {
+		dirs = append(dirs, dir)
+	}
+	sort.Strings(dirs)
+	var changes []compatChange
+	for _, dir := range dirs {
+		oldStructs := oldPkgs[dir].exportedStructs()
+		newStructs := map[string]*types.Struct{}
+		if newPkg := newPkgs[dir]; newPkg != nil {
+			newStructs = newPkg.exportedStructs()
+		}
+		var names []string
+		for name := range oldStructs {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+		for _, name := range names {
+			newSt, ok := newStructs[name]
+			if !ok {
+				changes = append(changes, compatChange{Package: dir, Struct: name, Kind: "removed_struct",
+					Message: "struct is removed"})
+				continue
+			}
+			for _, key := range keys {
+				for _, c := range compareStructs(wireEncoders[key], oldStructs[name], newSt) {
+					c.Package, c.Struct = dir, name
+					changes = append(changes, c)
+				}
+			}
+		}
+	}
+	return changes
+}
+
+func hasOption(v *TagValue, key string) bool {
+	if v == nil {
+		return false
+	}
+	_, ok := v.Option(key)
+	return ok
+}
+
+// typeString returns the type name qualified by package name, the packages of two revisions are different objects
+func typeString(t types.Type) string {
+	return types.TypeString(t, func(p *types.Package) string {
+		return p.Name()
+	})
+}
+
+func lookupWireField(fields []wireField, path []string) (wireField, bool) {
+	for _, f := range fields {
+		if reflect.DeepEqual(f.Path, path) {
+			return f, true
+		}
+	}
+	return wireField{}, false
+}
+
+// lookupFieldTag returns the tag value of key of the field in path
+func lookupFieldTag(st *types.Struct, path []string, key string) (string, bool) {
+	for i, name := range path {
+		found := false
+		for j := 0; j < st.NumFields(); j++ {
+			if st.Field(j).Name() != name {
+				continue
+			}
+			if i == len(path)-1 {
+				return reflect.StructTag(st.Tag(j)).Lookup(key)
+			}
+			st, found = derefStruct(st.Field(j).Type())
+			break
+		}
+		if !found {
+			return "", false
+		}
+	}
+	return "", false
+}
+
+func writeCompatChanges(out io.Writer, format string, changes []compatChange) error {
+	if format == "json" {
+		if changes == nil {
+			changes = []compatChange{}
+		}
+		data, err := json.MarshalIndent(changes, "", "  ")
+		if err != nil {
+			return err
+		}
+		_, err = fmt.Fprintf(out, "%s\n", data)
+		return err
+	}
+	for _, c := range changes {
+		if _, err := fmt.Fprintln(out, c.String()); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func compatMain(args []string) error {
+	flags := flag.NewFlagSet("compat", flag.ContinueOnError)
+	keysArg := flags.String("keys", "json", "the serialization keys to compare e.g json,yaml,bson,xml")
+	format := flags.String("format", "text", "output format text|json")
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+	if *format != "text" && *format != "json" {
+		return errors.New("unsupported format " + *format + " please check 'format' arg")
+	}
+	var keys []string
+	for _, key := range strings.Split(*keysArg, ",") {
+		key = strings.TrimSpace(key)
+		if key == "" {
+			continue
+		}
+		if _, ok := wireEncoders[key]; !ok {
+			return errors.New("unsupported key " + key + " please check 'keys' arg")
+		}
+		keys = append(keys, key)
+	}
+	if flags.NArg() < 2 {
+		return errors.New("compat requires the old and new revisions")
+	}
+	paths := flags.Args()[2:]
+	if len(paths) == 0 {
+		paths = []string{"."}
+	}
+	top, err := gitTopLevel(".")
+	if err != nil {
+		return err
+	}
+	fs := token.NewFileSet()
+	oldPkgs, err := revisionPackages(fs, top, flags.Arg(0), paths)
+	if err != nil {
+		return err
+	}
+	newPkgs, err := revisionPackages(fs, top, flags.Arg(1), paths)
+	if err != nil {
+		return err
+	}
+	changes := comparePackages(oldPkgs, newPkgs, keys)
+	if len(changes) != 0 && exitCode == 0 {
+		exitCode = 1
+	}
+	return writeCompatChanges(os.Stdout, *format, changes)
+}
@@ -0,0 +1,45 @@
+/*
+ * Copyright 2020 bigpigeon. All rights reserved.
+ * Use of this source code is governed by a MIT style
+ * license that can be found in the LICENSE file.
+ *
+ */
+
+package main
+
+import (
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go/ast"
+	"go/parser"
+	"go/token"
+	"testing"
+)
+
+func TestComparePackages(t *testing.T) {
+	fs := token.NewFileSet()
+	load := func(src string) map[string]*typedPackage {
+		f, err := parser.ParseFile(fs, "api.go", src, parser.ParseComments)
+		require.NoError(t, err)
+		return map[string]*typedPackage{"api": checkPackage(fs, "api", []*ast.File{f})}
+	}
+	oldPkgs := load("package api\n\ntype Base struct {\n\tCreated int `json:\"created\"`\n}\n\n" +
+		"type User struct {\n\tBase\n\tID    int    `json:\"id\"`\n\tName  string `json:\"name\"`\n\tEmail string `json:\"email,omitempty\"`\n" +
+		"\tAge   int    `json:\"age\"`\n\tNick  string\n}\n\ntype Order struct{}\n")
+	newPkgs := load("package api\n\ntype Base struct {\n\tCreated int `json:\"created\"`\n}\n\n" +
+		"type User struct {\n\tBase\n\tID    int64  `json:\"id\"`\n\tName  string `json:\"full_name\"`\n\tEmail string `json:\"email\"`\n" +
+		"\tAge   int    `json:\"-\"`\n\tNickName string `json:\"Nick\"`\n}\n")
+	var messages []string
+	for _, c := range comparePackages(oldPkgs, newPkgs, []string{"json", "yaml"}) {
+		messages = append(messages, c.String())
+	}
+	assert.Equal(t, []string{
+		`api: Order: struct is removed`,
+		`api: User: json: key "age" is ignored by "-"`,
+		`api: User: json: omitempty is removed from key "email"`,
+		`api: User: json: type of key "id" is changed from int to int64`,
+		`api: User: json: key "name" is renamed to "full_name"`,
+		`api: User: yaml: type of key "id" is changed from int to int64`,
+		`api: User: yaml: key "nick" is removed`,
+	}, messages)
+}
@@ -0,0 +1,171 @@
+/*
+ * Copyright 2020 bigpigeon. All rights reserved.
+ * Use of this source code is governed by a MIT style
+ * license that can be found in the LICENSE file.
+ *
+ */
+
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+// configName is the config file name, it's searched from the directory of file up to the root
+const configName = ".tagfmt"
+
+// configFlagNames is the flags can be set in config, the others are about how to run tagfmt
+var configFlagNames = map[string]bool{
+	"a": true, "s": true, "so": true, "sw": true, "f": true, "normalize": true, "fix": true,
+	"p": true, "P": true, "sp": true, "sP": true, "remove": true, "allow-keys": true,
+	"prune-empty": true, "prune-keys": true, "doc-from": true, "fill-ignored": true,
+}
+
+// configLine is a 'name = value' line in config
+type configLine struct {
+	Name  string
+	Value string
+	Line  int
+}
+
+// tagConfig is the parsed config file
+type tagConfig struct {
+	Path     string
+	Flags    []configLine
+	Policies []*tagPolicy
+}
+
+// explicitFlags is the flags set in command line, config doesn't override them
+var explicitFlags = map[string]bool{}
+
+// configCache is the config of directories, nil means no config in directory and its parents
+var configCache = map[string]*tagConfig{}
+
+// parseConfig parses the config, a line is 'name = value', the line starts with '#' is comment,
+// the policy lines are 'policy = <conditions> => require|forbid <keys>'
+func parseConfig(path string, data []byte) (*tagConfig, error) {
+	cfg := &tagConfig{Path: path}
+	base, err := filepath.Abs(filepath.Dir(path))
+	if err != nil {
+		return nil, err
+	}
+	// the source of policy in messages
+	display := path
+	if wd, err := os.Getwd(); err == nil {
+		if rel, err := filepath.Rel(wd, path); err == nil {
+			display = rel
+		}
+	}
+	for i, line := range strings.Split(string(data), "\n") {
+		line = strings.TrimSpace(line)
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		nameValue := strings.SplitN(line, "=", 2)
+		if len(nameValue) != 2 {
+			return nil, fmt.Errorf("%s:%d: invalid config line, expect 'name = value'", path, i+1)
+		}
+		name, value := strings.TrimSpace(nameValue[0]), strings.TrimSpace(nameValue[1])
+		if len(value) >= 2 && value[0] == '"' {
+			unquoted, err := strconv.Unquote(value)
+			if err != nil {
+				return nil, fmt.Errorf("%s:%d: %s", path, i+1, err)
+			}
+			value = unquoted
+		} else if len(value) >= 2 && value[0] == '\'' && value[len(value)-1] == '\'' {
+			value = value[1 : len(value)-1]
+		}
+		if name == "policy" {
+			policy, err := parsePolicy(fmt.Sprintf("%s:%d", display, i+1), base, value)
+			if err != nil {
+				return nil, fmt.Errorf("%s:%d: %s", path, i+1, err)
+			}
+			cfg.Policies = append(cfg.Policies, policy)
+			continue
+		}
+		if !configFlagNames[name] {
+			return nil, fmt.Errorf("%s:%d: unknown config %s", path, i+1, name)
+		}
+		cfg.Flags = append(cfg.Flags, configLine{Name: name, Value: value, Line: i + 1})
+	}
+	return cfg, nil
+}
+
+// findConfig returns the nearest config of directory, nil if there is no config
+func findConfig(dir string) (*tagConfig, error) {
+	if cfg, ok := configCache[dir]; ok {
+		return cfg, nil
+	}
+	var cfg *tagConfig
+	path := filepath.Join(dir, configName)
+	data, err := ioutil.ReadFile(path)
+	if err == nil {
+		cfg, err = parseConfig(path, data)
+		if err != nil {
+			return nil, err
+		}
+	} else if !os.IsNotExist(err) {
+		return nil, err
+	} else if parent := filepath.Dir(dir); parent != dir {
+		cfg, err = findConfig(parent)
+		if err != nil {
+			return nil, err
+		}
+	}
+	configCache[dir] = cfg
+	return cfg, nil
+}
+
+// applyConfig sets the flags in config of file which are not set in command line,
+// the returned function restores them
+func applyConfig(filename string) (func(), error) {
+	abs, err := filepath.Abs(filename)
+	if err != nil {
+		return func() {}, err
+	}
+	cfg, err := findConfig(filepath.Dir(abs))
+	if err != nil || cfg == nil {
+		return func() {}, err
+	}
+	old := map[string]string{}
+	oldPolicies, oldLookupBase := activePolicies, lookupBase
+	activePolicies = cfg.Policies
+	restore := func() {
+		for name, value := range old {
+			flag.Lookup(name).Value.Set(value)
+		}
+		activePolicies, lookupBase = oldPolicies, oldLookupBase
+	}
+	for _, line := range cfg.Flags {
+		if explicitFlags[line.Name] {
+			continue
+		}
+		f := flag.Lookup(line.Name)
+		if _, ok := old[line.Name]; !ok {
+			old[line.Name] = f.Value.String()
+		}
+		if err := f.Value.Set(line.Value); err != nil {
+			restore()
+			return func() {}, fmt.Errorf("%s:%d: %s", cfg.Path, line.Line, err)
+		}
+		// the mapping files of lookup in fill rule are relative to config
+		if line.Name == "f" {
+			lookupBase = filepath.Dir(cfg.Path)
+		}
+	}
+	return restore, nil
+}
+
+// recordExplicitFlags records the flags set in command line
+func recordExplicitFlags() {
+	explicitFlags = map[string]bool{}
+	flag.Visit(func(f *flag.Flag) {
+		explicitFlags[f.Name] = true
+	})
+}
@@ -0,0 +1,73 @@
+/*
+ * Copyright 2020 bigpigeon. All rights reserved.
+ * Use of this source code is governed by a MIT style
+ * license that can be found in the LICENSE file.
+ *
+ */
+
+package main
+
+import (
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go/token"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestParseConfig(t *testing.T) {
+	cfg, err := parseConfig(".tagfmt", []byte("# style\ns = true\nso = json|yaml\n\nf = \"json=snake(:field)\"\np = '^[A-Z]'\n"))
+	require.NoError(t, err)
+	assert.Equal(t, []configLine{
+		{Name: "s", Value: "true", Line: 2},
+		{Name: "so", Value: "json|yaml", Line: 3},
+		{Name: "f", Value: "json=snake(:field)", Line: 5},
+		{Name: "p", Value: "^[A-Z]", Line: 6},
+	}, cfg.Flags)
+	for data, msg := range map[string]string{
+		"s true":          ".tagfmt:1: invalid config line, expect 'name = value'",
+		"\nw = true":      ".tagfmt:2: unknown config w",
+		"f = \"json=\\\"": ".tagfmt:1: invalid syntax",
+	} {
+		_, err := parseConfig(".tagfmt", []byte(data))
+		assert.EqualError(t, err, msg, data)
+	}
+}
+
+func TestApplyConfig(t *testing.T) {
+	resetFlags()
+	initParserMode()
+	dir, err := ioutil.TempDir("", "tagfmt_config")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+	configCache = map[string]*tagConfig{}
+	defer func() {
+		configCache = map[string]*tagConfig{}
+		explicitFlags = map[string]bool{}
+	}()
+	require.NoError(t, os.MkdirAll(filepath.Join(dir, "api", "v1"), 0755))
+	require.NoError(t, ioutil.WriteFile(filepath.Join(dir, configName), []byte("s = true\na = false\nso = yaml|json\n"), 0644))
+	filename := filepath.Join(dir, "api", "v1", "user.go")
+
+	explicitFlags = map[string]bool{"a": true}
+	restore, err := applyConfig(filename)
+	require.NoError(t, err)
+	assert.True(t, *tagSort)
+	assert.True(t, *align)
+	assert.Equal(t, "yaml|json", *tagSortOrder)
+	res, err := formatSource(filename, []byte("package v1\n\ntype User struct {\n\tID int `json:\"id\" yaml:\"id\"`\n}\n"))
+	require.NoError(t, err)
+	assert.Equal(t, "package v1\n\ntype User struct {\n\tID int `yaml:\"id\" json:\"id\"`\n}\n", string(res))
+	restore()
+	assert.False(t, *tagSort)
+	assert.Equal(t, "", *tagSortOrder)
+
+	// no config
+	fileSet = token.NewFileSet()
+	restore, err = applyConfig(filepath.Join(os.TempDir(), "user.go"))
+	require.NoError(t, err)
+	restore()
+	assert.False(t, *tagSort)
+}
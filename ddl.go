@@ -0,0 +1,474 @@
+/*
+ * Copyright 2020 bigpigeon. All rights reserved.
+ * Use of this source code is governed by a MIT style
+ * license that can be found in the LICENSE file.
+ *
+ */
+
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"go/ast"
+	"go/parser"
+	"go/token"
+	"io"
+	"io/ioutil"
+	"os"
+	"sort"
+	"strings"
+)
+
+// ddlTableDirective is the comment of struct specifies its table e.g //tagfmt:table user_accounts
+const ddlTableDirective = "tagfmt:table"
+
+// gormModelColumns is the columns of embedded gorm.Model
+var gormModelColumns = []string{"id", "created_at", "updated_at", "deleted_at"}
+
+var (
+	// ddlSync is set by ddl command, nil means ddl sync is disabled
+	ddlSync *ddlSyncOption
+	// ddlOut is where the fields without column and columns without field are reported in fill mode
+	ddlOut io.Writer = os.Stderr
+)
+
+// ddlSyncOption is the schema and the keys filled from it
+type ddlSyncOption struct {
+	schema ddlSchema
+	keys   []string
+}
+
+// tagDDLSyncer fills the keys of fields from the columns of table matches the struct,
+// it only reports the mismatches if check is enabled
+type tagDDLSyncer struct {
+	f           *ast.File
+	fs          *token.FileSet
+	filename    string
+	option      *ddlSyncOption
+	check       bool
+	types       map[string]*ast.TypeSpec
+	diagnostics []tagDiagnostic
+}
+
+// Scan fills the column tags of fields
+func (s *tagDDLSyncer) Scan() error {
+	s.types = localTypeSpecs(s.filename, s.f)
+	ast.Walk(s, s.f)
+	return nil
+}
+
+func (s *tagDDLSyncer) Execute() error {
+	return nil
+}
+
+func (s *tagDDLSyncer) Visit(node ast.Node) ast.Visitor {
+	cmap := ast.NewCommentMap(s.fs, node, s.f.Comments)
+	visit := newScopeVisit(cmap, s.executor)
+	return visit.Visit(node)
+}
+
+// tableOf returns the table of struct, the directive is used first, then the snake case
+// name and its plural, the error is set if the table of directive isn't found
+func (s *tagDDLSyncer) tableOf(name string, comments []*ast.CommentGroup) (*ddlTable, error) {
+	for _, group := range comments {
+		for _, c := range group.List {
+			text := strings.TrimSpace(strings.TrimPrefix(c.Text, "//"))
+			if !strings.HasPrefix(text, ddlTableDirective+" ") {
+				continue
+			}
+			table := strings.TrimSpace(text[len(ddlTableDirective):])
+			if t := s.option.schema[strings.ToLower(table)]; t != nil {
+				return t, nil
+			}
+			return nil, errors.New("table " + table + " of " + name + " is not found")
+		}
+	}
+	snake := snakeConvert(name)
+	for _, candidate := range []string{snake, pluralize(snake)} {
+		if t := s.option.schema[candidate]; t != nil {
+			return t, nil
+		}
+	}
+	return nil, nil
+}
+
+// pluralize returns the plural of english word in the way of gorm table name
+func pluralize(s string) string {
+	switch {
+	case strings.HasSuffix(s, "y") && len(s) > 1 && !strings.ContainsAny(s[len(s)-2:len(s)-1], "aeiou"):
+		return s[:len(s)-1] + "ies"
+	case strings.HasSuffix(s, "s") || strings.HasSuffix(s, "x") || strings.HasSuffix(s, "ch") || strings.HasSuffix(s, "sh"):
+		return s + "es"
+	}
+	return s + "s"
+}
+
+func (s *tagDDLSyncer) executor(scope *structScope, comments []*ast.CommentGroup, n *ast.StructType) {
+	// only the named struct is a table
+	if n.Fields == nil || scope.Name == "" || scope.Parent != nil {
+		return
+	}
+	table, err := s.tableOf(scope.Name, comments)
+	if err != nil {
+		s.report(n, err.Error())
+		return
+	}
+	if table == nil {
+		return
+	}
+	covered := map[string]bool{}
+	complete := s.coverEmbedded(n, covered, 0)
+	for _, field := range n.Fields.List {
+		if len(field.Names) != 1 || !ast.IsExported(field.Names[0].Name) || s.isRelation(field) {
+			continue
+		}
+		column, ok := fieldColumn(field)
+		if !ok {
+			continue
+		}
+		// the excluded field still covers its column
+		covered[strings.ToLower(column)] = true
+		if !fieldFilter(field.Names[0].Name) {
+			continue
+		}
+		c := table.column(column)
+		if c == nil {
+			s.report(field, fmt.Sprintf("field %s.%s has no column %s in table %s (%s)", scope.Name, field.Names[0].Name, column, table.Name, table.Pos))
+			continue
+		}
+		s.sync(scope.Name, field, c)
+	}
+	if !complete {
+		// the unknown embedded type may have the columns
+		return
+	}
+	for _, c := range table.Columns {
+		if !covered[strings.ToLower(c.Name)] {
+			s.report(n, fmt.Sprintf("column %s of table %s (%s) has no field in %s", c.Name, table.Name, table.Pos, scope.Name))
+		}
+	}
+}
+
+// coverEmbedded marks the columns of embedded fields, it returns false if some embedded type is unknown
+func (s *tagDDLSyncer) coverEmbedded(n *ast.StructType, covered map[string]bool, depth int) bool {
+	complete := true
+	for _, field := range n.Fields.List {
+		if len(field.Names) != 0 {
+			if depth != 0 && ast.IsExported(field.Names[0].Name) && !s.isRelation(field) {
+				if column, ok := fieldColumn(field); ok {
+					covered[strings.ToLower(column)] = true
+				}
+			}
+			continue
+		}
+		typeName, local := embeddedTypeName(field.Type)
+		if sel, ok := field.Type.(*ast.SelectorExpr); ok && typeName == "Model" {
+			if pkg, ok := sel.X.(*ast.Ident); ok && pkg.Name == "gorm" {
+				for _, column := range gormModelColumns {
+					covered[column] = true
+				}
+				continue
+			}
+		}
+		spec := s.types[typeName]
+		st, isStruct := (*ast.StructType)(nil), false
+		if local && spec != nil {
+			st, isStruct = spec.Type.(*ast.StructType)
+		}
+		if !isStruct || depth > 8 || !s.coverEmbedded(st, covered, depth+1) {
+			complete = false
+		}
+	}
+	return complete
+}
+
+// isRelation reports whether the field is the association of gorm rather than a column,
+// e.g the slice, map and local struct types and the fields with foreign key options
+func (s *tagDDLSyncer) isRelation(field *ast.Field) bool {
+	if field.Tag != nil {
+		if _, keyValues, err := ParseTag(field.Tag.Value); err == nil {
+			for _, kv := range keyValues {
+				if kv.Key != "gorm" {
+					continue
+				}
+				v, err := gormGrammar.Parse(kv.Value)
+				if err != nil {
+					continue
+				}
+				for _, opt := range v.Options {
+					switch gormOptionName(opt.Key) {
+					case "FOREIGNKEY", "REFERENCES", "MANY2MANY", "POLYMORPHIC", "EMBEDDED":
+						return true
+					}
+				}
+			}
+		}
+	}
+	typ := field.Type
+	if star, ok := typ.(*ast.StarExpr); ok {
+		typ = star.X
+	}
+	switch t := typ.(type) {
+	case *ast.ArrayType:
+		elt, ok := t.Elt.(*ast.Ident)
+		return !ok || elt.Name != "byte"
+	case *ast.MapType:
+		return true
+	case *ast.StructType:
+		return true
+	case *ast.Ident:
+		if spec := s.types[t.Name]; spec != nil {
+			_, isStruct := spec.Type.(*ast.StructType)
+			return isStruct
+		}
+	}
+	return false
+}
+
+// gormOptionName is the option key gorm uses, gorm parses the keys case insensitively
+func gormOptionName(key string) string {
+	return strings.ToUpper(strings.Replace(key, "_", "", -1))
+}
+
+// fieldColumn returns the column name of field, the name in gorm column option or db tag is used first,
+// false if the field is ignored by gorm or db
+func fieldColumn(field *ast.Field) (string, bool) {
+	name := field.Names[0].Name
+	if field.Tag == nil {
+		return snakeConvert(name), true
+	}
+	_, keyValues, err := ParseTag(field.Tag.Value)
+	if err != nil {
+		return "", false
+	}
+	column := ""
+	for _, kv := range keyValues {
+		switch kv.Key {
+		case "gorm":
+			if kv.Value == "-" {
+				return "", false
+			}
+			v, err := gormGrammar.Parse(kv.Value)
+			if err != nil {
+				continue
+			}
+			for _, opt := range v.Options {
+				if gormOptionName(opt.Key) == "COLUMN" && opt.Value != "" {
+					return opt.Value, true
+				}
+			}
+		case "db":
+			v, err := commaNamedGrammar.Parse(kv.Value)
+			if err != nil {
+				continue
+			}
+			if v.Name == "-" {
+				return "", false
+			}
+			column = v.Name
+		}
+	}
+	if column == "" {
+		column = snakeConvert(name)
+	}
+	return column, true
+}
+
+// sync fills the keys from column, or reports the mismatches if check is enabled
+func (s *tagDDLSyncer) sync(structName string, field *ast.Field, c *ddlColumn) {
+	quote, keyValues := "`", []KeyValue(nil)
+	if field.Tag != nil {
+		var err error
+		quote, keyValues, err = ParseTag(field.Tag.Value)
+		if err != nil {
+			// reported by tag doctor
+			return
+		}
+	}
+	if quote != "`" {
+		return
+	}
+	changed := false
+	for _, key := range s.option.keys {
+		index := -1
+		for i, kv := range keyValues {
+			if kv.Key == key {
+				index = i
+				break
+			}
+		}
+		old := ""
+		if index != -1 {
+			old = keyValues[index].Value
+		}
+		value := ddlTagValue(key, old, c)
+		if index != -1 && value == old {
+			continue
+		}
+		if s.check {
+			expect := KeyValue{Key: key, quote: quote, Value: value}.String()
+			if index == -1 {
+				s.report(field, fmt.Sprintf("field %s.%s has no %s tag of column %s, expect %s", structName, field.Names[0].Name, key, c.Name, expect))
+			} else {
+				s.report(field, fmt.Sprintf("field %s.%s %s doesn't match column %s, expect %s", structName, field.Names[0].Name, keyValues[index].String(), c.Name, expect))
+			}
+			continue
+		}
+		changed = true
+		if index == -1 {
+			keyValues = append(keyValues, KeyValue{Key: key, quote: quote, Value: value})
+		} else {
+			keyValues[index].Value = value
+		}
+	}
+	if !changed {
+		return
+	}
+	if field.Tag == nil {
+		field.Tag = &ast.BasicLit{Kind: token.STRING, ValuePos: field.Type.End()}
+	}
+	field.Tag.Value = joinTag(quote, keyValues)
+	field.Tag.ValuePos = 0
+}
+
+// ddlTagValue returns the value of key follows the column, the other options of old value are kept
+func ddlTagValue(key, old string, c *ddlColumn) string {
+	switch key {
+	case "gorm":
+		v, err := gormGrammar.Parse(old)
+		if err != nil {
+			return old
+		}
+		setGormOption(v, "column", c.Name, true)
+		setGormOption(v, "type", c.Type, c.Type != "")
+		setGormOption(v, "primaryKey", "", c.PrimaryKey)
+		setGormOption(v, "not null", "", c.NotNull)
+		return gormGrammar.Print(v)
+	case "db":
+		v, err := commaNamedGrammar.Parse(old)
+		if err != nil {
+			return old
+		}
+		v.Name = c.Name
+		return commaNamedGrammar.Print(v)
+	}
+	return old
+}
+
+// setGormOption sets the option if enabled, or removes it, the value equals case insensitively is kept
+func setGormOption(v *TagValue, key, value string, enabled bool) {
+	hasValue := value != ""
+	for i, opt := range v.Options {
+		if gormOptionName(opt.Key) != gormOptionName(key) {
+			continue
+		}
+		if !enabled {
+			v.Options = append(v.Options[:i], v.Options[i+1:]...)
+		} else if !strings.EqualFold(opt.Value, value) || opt.HasValue != hasValue {
+			v.Options[i].Value, v.Options[i].HasValue = value, hasValue
+		}
+		return
+	}
+	if !enabled {
+		return
+	}
+	opt := TagOption{Key: key, Value: value, HasValue: hasValue}
+	if key == "column" {
+		// column is the first option by convention
+		v.Options = append([]TagOption{opt}, v.Options...)
+	} else {
+		v.Options = append(v.Options, opt)
+	}
+}
+
+func (s *tagDDLSyncer) report(n ast.Node, msg string) {
+	diag := newTagDiagnostic(s.fs, n, "ddl: "+msg)
+	s.diagnostics = append(s.diagnostics, diag)
+	if !s.check {
+		fmt.Fprintln(ddlOut, diag.String())
+	}
+}
+
+func newTagDDLSync(f *ast.File, fs *token.FileSet, filename string, option *ddlSyncOption, check bool) *tagDDLSyncer {
+	return &tagDDLSyncer{f: f, fs: fs, filename: filename, option: option, check: check}
+}
+
+func ddlMain(args []string) error {
+	flags := flag.NewFlagSet("ddl", flag.ContinueOnError)
+	dir := flags.String("dir", "migrations", "the directory of .sql files with CREATE TABLE statements")
+	keysArg := flags.String("keys", "gorm", "the keys filled from columns e.g gorm,db")
+	check := flags.Bool("check", false, "report the tags mismatch the columns instead of rewriting files")
+	flags.BoolVar(write, "w", *write, "write result to (source) file instead of stdout")
+	flags.BoolVar(list, "l", *list, "list files whose formatting differs from tagfmt's")
+	flags.BoolVar(doDiff, "d", *doDiff, "display diffs instead of rewriting files")
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+	option := &ddlSyncOption{}
+	for _, key := range strings.Split(*keysArg, ",") {
+		key = strings.TrimSpace(key)
+		if key == "" {
+			continue
+		}
+		if key != "gorm" && key != "db" {
+			return errors.New("unsupported key " + key + " please check 'keys' arg")
+		}
+		option.keys = append(option.keys, key)
+	}
+	schema, err := loadDDLSchema(*dir)
+	if err != nil {
+		return err
+	}
+	if len(schema) == 0 {
+		return errors.New("no table is found in " + *dir)
+	}
+	option.schema = schema
+	if *check {
+		return ddlCheck(option, flags.Args())
+	}
+	ddlSync = option
+	defer func() { ddlSync = nil }()
+	processArgs(flags.Args())
+	return nil
+}
+
+// ddlCheck prints the mismatches of tags and columns, exit with 1 if there is any
+func ddlCheck(option *ddlSyncOption, paths []string) error {
+	if err := selectInitFromFlags(); err != nil {
+		return err
+	}
+	if len(paths) == 0 {
+		paths = []string{"."}
+	}
+	filenames, err := goFiles(paths)
+	if err != nil {
+		return err
+	}
+	fs := token.NewFileSet()
+	for _, filename := range filenames {
+		src, err := ioutil.ReadFile(filename)
+		if err != nil {
+			report(err)
+			continue
+		}
+		file, err := parser.ParseFile(fs, filename, src, parserMode)
+		if err != nil {
+			report(err)
+			continue
+		}
+		syncer := newTagDDLSync(file, fs, filename, option, true)
+		syncer.Scan()
+		sort.SliceStable(syncer.diagnostics, func(i, j int) bool {
+			return syncer.diagnostics[i].Pos.Offset < syncer.diagnostics[j].Pos.Offset
+		})
+		for _, diag := range syncer.diagnostics {
+			fmt.Println(diag.String())
+		}
+		if len(syncer.diagnostics) != 0 && exitCode == 0 {
+			exitCode = 1
+		}
+	}
+	return nil
+}
@@ -0,0 +1,138 @@
+/*
+ * Copyright 2020 bigpigeon. All rights reserved.
+ * Use of this source code is governed by a MIT style
+ * license that can be found in the LICENSE file.
+ *
+ */
+
+package main
+
+import (
+	"bytes"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go/parser"
+	"go/token"
+	"os"
+	"testing"
+)
+
+const ddlTestSQL = `CREATE TABLE users (
+    id bigint PRIMARY KEY,
+    name varchar(64) NOT NULL,
+    email varchar(128),
+    nickname varchar(32)
+);
+CREATE TABLE user_accounts (
+    id bigint PRIMARY KEY
+);`
+
+const ddlTestSrc = `package main
+
+type User struct {
+	ID     int64
+	Name   string ` + "`json:\"name\" gorm:\"type:varchar(32);index\"`" + `
+	Email  string ` + "`gorm:\"column:email;type:VARCHAR(128)\"`" + `
+	Age    int
+	Orders []Order
+}
+
+type Order struct {
+	ID int64
+}
+
+//tagfmt:table accounts
+type Account struct {
+	ID int64
+}
+`
+
+func TestDDLSync(t *testing.T) {
+	resetFlags()
+	initParserMode()
+	schema := ddlSchema{}
+	require.NoError(t, schema.parseDDL("001.sql", ddlTestSQL))
+	var out bytes.Buffer
+	ddlOut = &out
+	ddlSync = &ddlSyncOption{schema: schema, keys: []string{"gorm", "db"}}
+	defer func() {
+		ddlOut = os.Stderr
+		ddlSync = nil
+		resetFlags()
+	}()
+	*align = false
+	res, err := formatSource("ddl.go", []byte(ddlTestSrc))
+	require.NoError(t, err)
+	// the type equals case insensitively is kept
+	assert.Equal(t, `package main
+
+type User struct {
+	ID     int64  `+"`gorm:\"column:id;type:bigint;primaryKey\" db:\"id\"`"+`
+	Name   string `+"`json:\"name\" gorm:\"column:name;type:varchar(64);index;not null\" db:\"name\"`"+`
+	Email  string `+"`gorm:\"column:email;type:VARCHAR(128)\" db:\"email\"`"+`
+	Age    int
+	Orders []Order
+}
+
+type Order struct {
+	ID int64
+}
+
+//tagfmt:table accounts
+type Account struct {
+	ID int64
+}
+`, string(res))
+	assert.Equal(t, "ddl.go:7:2: ddl: field User.Age has no column age in table users (001.sql:1)\n"+
+		"ddl.go:3:11: ddl: column nickname of table users (001.sql:1) has no field in User\n"+
+		"ddl.go:16:14: ddl: table accounts of Account is not found\n", out.String())
+}
+
+func TestDDLCheck(t *testing.T) {
+	resetFlags()
+	initParserMode()
+	schema := ddlSchema{}
+	require.NoError(t, schema.parseDDL("001.sql", ddlTestSQL))
+	fs := token.NewFileSet()
+	file, err := parser.ParseFile(fs, "ddl.go", ddlTestSrc, parserMode)
+	require.NoError(t, err)
+	syncer := newTagDDLSync(file, fs, "ddl.go", &ddlSyncOption{schema: schema, keys: []string{"gorm"}}, true)
+	require.NoError(t, syncer.Scan())
+	var messages []string
+	for _, diag := range syncer.diagnostics {
+		messages = append(messages, diag.String())
+	}
+	assert.Equal(t, []string{
+		`ddl.go:4:2: ddl: field User.ID has no gorm tag of column id, expect gorm:"column:id;type:bigint;primaryKey"`,
+		`ddl.go:5:2: ddl: field User.Name gorm:"type:varchar(32);index" doesn't match column name, expect gorm:"column:name;type:varchar(64);index;not null"`,
+		`ddl.go:7:2: ddl: field User.Age has no column age in table users (001.sql:1)`,
+		`ddl.go:3:11: ddl: column nickname of table users (001.sql:1) has no field in User`,
+		`ddl.go:16:14: ddl: table accounts of Account is not found`,
+	}, messages)
+}
+
+func TestDDLCheckFieldFilter(t *testing.T) {
+	resetFlags()
+	initParserMode()
+	defer resetFlags()
+	*inversePattern = "^(Age|Email)$"
+	require.NoError(t, selectInitFromFlags())
+	schema := ddlSchema{}
+	require.NoError(t, schema.parseDDL("001.sql", ddlTestSQL))
+	fs := token.NewFileSet()
+	file, err := parser.ParseFile(fs, "ddl.go", ddlTestSrc, parserMode)
+	require.NoError(t, err)
+	syncer := newTagDDLSync(file, fs, "ddl.go", &ddlSyncOption{schema: schema, keys: []string{"gorm"}}, true)
+	require.NoError(t, syncer.Scan())
+	var messages []string
+	for _, diag := range syncer.diagnostics {
+		messages = append(messages, diag.String())
+	}
+	// the excluded fields are neither reported nor make their columns uncovered
+	assert.Equal(t, []string{
+		`ddl.go:4:2: ddl: field User.ID has no gorm tag of column id, expect gorm:"column:id;type:bigint;primaryKey"`,
+		`ddl.go:5:2: ddl: field User.Name gorm:"type:varchar(32);index" doesn't match column name, expect gorm:"column:name;type:varchar(64);index;not null"`,
+		`ddl.go:3:11: ddl: column nickname of table users (001.sql:1) has no field in User`,
+		`ddl.go:16:14: ddl: table accounts of Account is not found`,
+	}, messages)
+}
@@ -8,18 +8,66 @@ It uses blanks for alignment.
 tag must be in key:"value" pair format
 
 usage: tagfmt [flags] [path ...]
+   or: tagfmt compat [-keys json] [-format text|json] <old-rev> <new-rev> [packages]
+        report the breaking changes of serialized names between two git revisions
+   or: tagfmt [flags] ddl [-dir migrations] [-keys gorm,db] [-check] [-w|-l|-d] [path ...]
+        fill or check the gorm/db tags from the columns of CREATE TABLE statements
+   or: tagfmt [flags] freeze [-keys json] [-w|-l|-d] [path ...]
+        write the implicit serialized names of fields into tags
+   or: tagfmt [flags] init [-force] [dir]
+        write the .tagfmt config reproducing the tag style of existing code
+   or: tagfmt [flags] lsp
+        start language server over stdio, flags are used as the format options
+   or: tagfmt [flags] query [-format text|json] <predicate> [path ...]
+        print the fields match the predicate e.g 'has(json) && !option(json, "omitempty")'
+   or: tagfmt [flags] stats [-format text|json] [packages]
+        report the usage and naming conventions of tag keys
+   or: tagfmt vet [-keys json,yaml,bson] [packages]
+        report the serialized name collisions and shadowed fields
   -P string
         field name with inverse regular expression pattern
   -a    align with nearby field's tag (default true)
+  -allow-keys string
+        the allowed keys e.g json,yaml, the others are reported by -lint and removed by -fix
+  -at string
+        only process the innermost struct containing the position e.g file.go:123:5
   -cpuprofile string
         write cpu profile to this file
   -d    display diffs instead of rewriting files
+  -diff-base string
+        only process files and structs changed relative to the git ref e.g origin/main
+  -doc-from string
+        write the value of the key as doc comment of the field has no comment e.g desc
   -e    report all errors (not just the first 10 on different lines)
+  -edits string
+        display the text edits in the format instead of rewriting files, only json is supported
+  -explain
+        display the conventions inferred by -f auto
   -f string
-        fill key and value for field e.g json=lower(_val)|yaml=snake(_val)
+        fill key and value for field e.g json=lower(_val)|yaml=snake(_val), auto means infer the convention from existing tags
+  -fill-ignored string
+        how fill treats the fields with '-' value and unexported fields per key, modes are skip,preserve,flag,fill e.g json=flag,desc=fill, the encoder keys json,xml,yaml,toml,bson,mapstructure,gorm,db are skip by default, the others are fill
+  -fix
+        apply the suggested fixes of tag problems
   -l    list files whose formatting differs from tagfmt's
+  -lines string
+        only process structs overlapping the line range e.g 40:75
+  -lint
+        report problems of tags e.g unknown or conflicting options instead of rewriting files
+  -migrate string
+        move the option of old key to new key e.g db=gorm.column, the old key is kept if some of value can't be translated
+  -normalize string
+        normalize options in tag value, operations are space,dedup,order e.g json=space,dedup|gorm=order|*
   -p string
         field name with regular expression pattern (default ".*")
+  -prune-empty
+        remove the keys with empty value e.g xml:"" and the empty tags
+  -prune-keys string
+        only prune the empty value of the keys e.g xml,gorm
+  -remove string
+        remove the keys from tags e.g json,bson, the tag becomes empty is removed
+  -rename-key string
+        rename the keys and keep the values e.g form=query,mapstructure=koanf
   -s    sort struct tag by key
   -sP string
         struct name with inverse regular expression pattern
@@ -27,7 +75,17 @@ usage: tagfmt [flags] [path ...]
         sort struct tag keys order e.g json|yaml|desc
   -sp string
         struct name with regular expression pattern (default ".*")
+  -staged
+        only process staged structs, read from and write back to the git index
+  -stdin-filename string
+        the file path of standard input, used in error messages and position
+  -sw string
+        sort struct tag keys weight e.g json=1|yaml=2|desc=-1 the higher weight, the higher the ranking, default keys weight is 0
   -w    write result to (source) file instead of stdout
+  -watch
+        watch the paths and rewrite go files when they change
+  -watch-ignore string
+        ignored path patterns in watch mode e.g vendor|*_gen.go (default "vendor|testdata")
 
 
 
@@ -198,10 +256,19 @@ use `// tagfill: [key1 key2]` to filter below struct requires key
 		snake(s string) // convert upper_camel/lower_camel word to snake case
 		upper_camel(s string) // convert snake case/lower camel case to upper camel case
 		lower_camel(s string) // convert upper camel case/snake case to lower camel case
+		screaming_snake(s string) // convert to upper snake case, the dots become underscore
 		or(s string, s string) // return return first params if it's not zero,else return the second
+		lookup('file', s string) // return the value of key s in the json or csv mapping file, empty if not found
+		prefix(sep string, s string) // join the name of parent field and s with sep
 
 	fill rule placehold value:
 		:field // replace with struct field name
+		:struct // replace with struct name, anonymous struct uses the name of its named parent
+		:parent // replace with the field name of nested struct, empty in top struct
+		:path // replace with the dotted field names from top struct e.g Server.HTTP.Port
+		:pkg // replace with package name
+		:index // replace with the index of field in struct
+		:comment // replace with field doc, or line comment if there is no doc, the lines are joined and the quotes are escaped
 		:tag   // replace with  struct field existed tag's value
 		:tag_basic // replace with field existed tag's basic value (the value before the first ',' )
 		:tag_extra // replace with field existed tag's extra data (the value after the first ',' )
@@ -0,0 +1,85 @@
+/*
+ * Copyright 2020 bigpigeon. All rights reserved.
+ * Use of this source code is governed by a MIT style
+ * license that can be found in the LICENSE file.
+ *
+ */
+
+package main
+
+import (
+	"bytes"
+	"go/ast"
+	"go/parser"
+	"go/token"
+	"sort"
+	"strings"
+)
+
+// docInsertion is the comment line inserted at offset
+type docInsertion struct {
+	offset int
+	text   string
+}
+
+// insertDocComments writes the value of key as doc comment of the fields have neither doc nor line comment,
+// it works on the source before formatting so the tags are aligned with the comment lines
+func insertDocComments(filename string, src []byte, key string) ([]byte, error) {
+	// parse with the global fileSet, the range selection of structs uses it
+	file, err := parser.ParseFile(fileSet, filename, src, parserMode)
+	if err != nil {
+		return nil, err
+	}
+	var insertions []docInsertion
+	cmap := ast.NewCommentMap(fileSet, file, file.Comments)
+	ast.Walk(newTopVisit(cmap, func(name string, comments []*ast.CommentGroup, n *ast.StructType) {
+		insertions = append(insertions, fieldsDocInsertion(fileSet, src, n.Fields, key)...)
+	}), file)
+	if len(insertions) == 0 {
+		return src, nil
+	}
+	sort.Slice(insertions, func(i, j int) bool {
+		return insertions[i].offset < insertions[j].offset
+	})
+	var buf bytes.Buffer
+	last := 0
+	for _, ins := range insertions {
+		buf.Write(src[last:ins.offset])
+		buf.WriteString(ins.text)
+		last = ins.offset
+	}
+	buf.Write(src[last:])
+	return buf.Bytes(), nil
+}
+
+func fieldsDocInsertion(fs *token.FileSet, src []byte, fields *ast.FieldList, key string) []docInsertion {
+	var insertions []docInsertion
+	for _, field := range fields.List {
+		if field.Tag == nil || field.Doc != nil || field.Comment != nil || !fieldFilter(getFieldOrTypeName(field)) {
+			continue
+		}
+		_, keyValues, err := ParseTag(field.Tag.Value)
+		if err != nil {
+			continue
+		}
+		var text string
+		for _, kv := range keyValues {
+			if kv.Key == key {
+				text = strings.Join(strings.Fields(unescapeTagValue(kv.Value)), " ")
+				break
+			}
+		}
+		if text == "" || text == "-" {
+			continue
+		}
+		pos := fs.Position(field.Pos())
+		lineStart := pos.Offset - (pos.Column - 1)
+		indent := string(src[lineStart:pos.Offset])
+		// the field shares line with others e.g struct{ A int `desc:"a"` }
+		if strings.TrimSpace(indent) != "" {
+			continue
+		}
+		insertions = append(insertions, docInsertion{offset: lineStart, text: indent + "// " + text + "\n"})
+	}
+	return insertions
+}
@@ -0,0 +1,161 @@
+/*
+ * Copyright 2020 bigpigeon. All rights reserved.
+ * Use of this source code is governed by a MIT style
+ * license that can be found in the LICENSE file.
+ *
+ */
+
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"io"
+)
+
+// the line diff of larger file replace the whole changed part instead of matching lines
+const editsMaxMatrix = 4 << 20
+
+// textEdit replaces the src[StartOffset:EndOffset] with NewText,
+// line and column are 1-based and column is counted in bytes
+type textEdit struct {
+	StartOffset int    `json:"start_offset"`
+	EndOffset   int    `json:"end_offset"`
+	StartLine   int    `json:"start_line"`
+	StartCol    int    `json:"start_col"`
+	NewText     string `json:"new_text"`
+}
+
+type fileEdits struct {
+	File  string     `json:"file"`
+	Edits []textEdit `json:"edits"`
+}
+
+func writeEdits(out io.Writer, filename string, src, res []byte) error {
+	if *edits != "json" {
+		return errors.New("edits format error please check 'edits' arg, only json is supported")
+	}
+	data, err := json.Marshal(fileEdits{File: filename, Edits: computeEdits(src, res)})
+	if err != nil {
+		return err
+	}
+	_, err = out.Write(append(data, '\n'))
+	return err
+}
+
+// splitLines splits the data into lines, each line contains its '\n'
+func splitLines(data []byte) [][]byte {
+	var lines [][]byte
+	for len(data) != 0 {
+		i := bytes.IndexByte(data, '\n')
+		if i == -1 {
+			lines = append(lines, data)
+			break
+		}
+		lines = append(lines, data[:i+1])
+		data = data[i+1:]
+	}
+	return lines
+}
+
+// computeEdits returns the edits that convert src to res
+func computeEdits(src, res []byte) []textEdit {
+	a, b := splitLines(src), splitLines(res)
+	offsets := make([]int, len(a)+1)
+	for i, line := range a {
+		offsets[i+1] = offsets[i] + len(line)
+	}
+
+	type hunk struct{ a0, a1, b0, b1 int }
+	var hunks []hunk
+	addHunk := func(a0, a1, b0, b1 int) {
+		if a0 == a1 && b0 == b1 {
+			return
+		}
+		if n := len(hunks); n != 0 && hunks[n-1].a1 == a0 && hunks[n-1].b1 == b0 {
+			hunks[n-1].a1, hunks[n-1].b1 = a1, b1
+			return
+		}
+		hunks = append(hunks, hunk{a0, a1, b0, b1})
+	}
+
+	start := 0
+	for start < len(a) && start < len(b) && bytes.Equal(a[start], b[start]) {
+		start++
+	}
+	aEnd, bEnd := len(a), len(b)
+	for aEnd > start && bEnd > start && bytes.Equal(a[aEnd-1], b[bEnd-1]) {
+		aEnd--
+		bEnd--
+	}
+	n, m := aEnd-start, bEnd-start
+	if n*m > editsMaxMatrix {
+		addHunk(start, aEnd, start, bEnd)
+	} else {
+		// lcs[i][j] is the longest common lines of a[start+i:aEnd] and b[start+j:bEnd]
+		lcs := make([][]int, n+1)
+		for i := range lcs {
+			lcs[i] = make([]int, m+1)
+		}
+		for i := n - 1; i >= 0; i-- {
+			for j := m - 1; j >= 0; j-- {
+				if bytes.Equal(a[start+i], b[start+j]) {
+					lcs[i][j] = lcs[i+1][j+1] + 1
+				} else if lcs[i+1][j] >= lcs[i][j+1] {
+					lcs[i][j] = lcs[i+1][j]
+				} else {
+					lcs[i][j] = lcs[i][j+1]
+				}
+			}
+		}
+		i, j := 0, 0
+		for i < n || j < m {
+			switch {
+			case i < n && j < m && bytes.Equal(a[start+i], b[start+j]):
+				i++
+				j++
+			case j < m && (i == n || lcs[i][j+1] >= lcs[i+1][j]):
+				addHunk(start+i, start+i, start+j, start+j+1)
+				j++
+			default:
+				addHunk(start+i, start+i+1, start+j, start+j)
+				i++
+			}
+		}
+	}
+
+	result := []textEdit{}
+	for _, h := range hunks {
+		startOffset, endOffset := offsets[h.a0], offsets[h.a1]
+		oldText := src[startOffset:endOffset]
+		newText := bytes.Join(b[h.b0:h.b1], nil)
+		// narrow the edit to the changed bytes
+		prefix := 0
+		for prefix < len(oldText) && prefix < len(newText) && oldText[prefix] == newText[prefix] {
+			prefix++
+		}
+		suffix := 0
+		for suffix < len(oldText)-prefix && suffix < len(newText)-prefix &&
+			oldText[len(oldText)-1-suffix] == newText[len(newText)-1-suffix] {
+			suffix++
+		}
+		startOffset += prefix
+		line, col := offsetLineColumn(src, startOffset)
+		result = append(result, textEdit{
+			StartOffset: startOffset,
+			EndOffset:   endOffset - suffix,
+			StartLine:   line,
+			StartCol:    col,
+			NewText:     string(newText[prefix : len(newText)-suffix]),
+		})
+	}
+	return result
+}
+
+// offsetLineColumn returns the 1-based line and byte column of offset
+func offsetLineColumn(src []byte, offset int) (line, col int) {
+	line = bytes.Count(src[:offset], []byte{'\n'}) + 1
+	col = offset - (bytes.LastIndexByte(src[:offset], '\n') + 1) + 1
+	return line, col
+}
@@ -0,0 +1,51 @@
+/*
+ * Copyright 2020 bigpigeon. All rights reserved.
+ * Use of this source code is governed by a MIT style
+ * license that can be found in the LICENSE file.
+ *
+ */
+
+package main
+
+import (
+	"bytes"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"testing"
+)
+
+func applyEdits(src []byte, edits []textEdit) string {
+	var res []byte
+	pre := 0
+	for _, e := range edits {
+		res = append(res, src[pre:e.StartOffset]...)
+		res = append(res, e.NewText...)
+		pre = e.EndOffset
+	}
+	return string(append(res, src[pre:]...))
+}
+
+func TestComputeEdits(t *testing.T) {
+	src := "package api\n\ntype User struct {\n\tID string `json:\"id\" yaml:\"id\"`\n\tName string `json:\"name\" yaml:\"name\"`\n}\n\nvar a = 1\n"
+	res := "package api\n\ntype User struct {\n\tID   string `json:\"id\"   yaml:\"id\"`\n\tName string `json:\"name\" yaml:\"name\"`\n}\n\nvar a = 1\n"
+	edits := computeEdits([]byte(src), []byte(res))
+	assert.Equal(t, []textEdit{
+		{StartOffset: 36, EndOffset: 53, StartLine: 4, StartCol: 5, NewText: "  string `json:\"id\"  "},
+	}, edits)
+	assert.Equal(t, res, applyEdits([]byte(src), edits))
+
+	src = "a\nb\nc\nd\n"
+	res = "a\nc\nx\nd\ne\n"
+	assert.Equal(t, res, applyEdits([]byte(src), computeEdits([]byte(src), []byte(res))))
+	assert.Empty(t, computeEdits([]byte(src), []byte(src)))
+}
+
+func TestWriteEditsEmpty(t *testing.T) {
+	resetFlags()
+	defer resetFlags()
+	*edits = "json"
+	src := []byte("package api\n")
+	var buf bytes.Buffer
+	require.NoError(t, writeEdits(&buf, "api.go", src, src))
+	assert.Equal(t, "{\"file\":\"api.go\",\"edits\":[]}\n", buf.String())
+}
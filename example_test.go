@@ -14,7 +14,7 @@ import (
 	"strings"
 )
 
-func ExampleAlignWrite() {
+func Example_alignWrite() {
 	resetFlags()
 	bakData, err := ioutil.ReadFile("exampledata/api.go")
 	if err != nil {
@@ -54,7 +54,7 @@ func ExampleAlignWrite() {
 	//}
 }
 
-func ExampleAlign() {
+func Example_align() {
 	resetFlags()
 	os.Args = strings.Split("tagfmt exampledata/", " ")
 	gofmtMain()
@@ -0,0 +1,126 @@
+/*
+ * Copyright 2020 bigpigeon. All rights reserved.
+ * Use of this source code is governed by a MIT style
+ * license that can be found in the LICENSE file.
+ *
+ */
+
+package main
+
+import (
+	"errors"
+	"go/ast"
+	"io"
+	"os"
+	"strings"
+)
+
+// fillIgnoredOut is where the flagged fields of fill are reported
+var fillIgnoredOut io.Writer = os.Stderr
+
+// ignoreMode is how the fill rule of key treats the fields ignored by encoder,
+// they are the fields with ignore marker e.g json:"-" and the unexported fields
+type ignoreMode int
+
+const (
+	// ignoreFill fills them as other fields
+	ignoreFill ignoreMode = iota
+	// ignoreSkip keeps the markers and doesn't fill the key of unexported fields
+	ignoreSkip
+	// ignorePreserve only keeps the markers, the unexported fields are filled
+	ignorePreserve
+	// ignoreFlag skips them as ignoreSkip and reports the unexported fields have the key
+	ignoreFlag
+)
+
+var ignoreModeNames = map[string]ignoreMode{
+	"fill":     ignoreFill,
+	"skip":     ignoreSkip,
+	"preserve": ignorePreserve,
+	"flag":     ignoreFlag,
+}
+
+// ignoreModes is the mode of keys, '*' is the mode of keys not listed
+type ignoreModes map[string]ignoreMode
+
+// encoderIgnoreKeys is the keys whose encoder ignores the field with '-' value and the unexported fields,
+// they are skipped by default because filling them changes nothing but the tag
+var encoderIgnoreKeys = map[string]bool{
+	"json": true, "xml": true, "yaml": true, "toml": true, "bson": true, "mapstructure": true, "gorm": true, "db": true,
+}
+
+// Mode returns the mode of key, the encoder keys are skipped and the others are filled by default
+func (m ignoreModes) Mode(key string) ignoreMode {
+	if mode, ok := m[key]; ok {
+		return mode
+	}
+	if mode, ok := m["*"]; ok {
+		return mode
+	}
+	if encoderIgnoreKeys[key] {
+		return ignoreSkip
+	}
+	return ignoreFill
+}
+
+// parseIgnoreModes parses the comma separated modes e.g json=flag,desc=fill,*=skip
+func parseIgnoreModes(s string) (ignoreModes, error) {
+	modes := ignoreModes{}
+	for _, item := range strings.Split(s, ",") {
+		item = strings.TrimSpace(item)
+		if item == "" {
+			continue
+		}
+		keyMode := strings.Split(item, "=")
+		if len(keyMode) != 2 || strings.TrimSpace(keyMode[0]) == "" {
+			return nil, errors.New("invalid mode " + item + " please check 'fill-ignored' arg, expect key=skip|preserve|flag|fill")
+		}
+		mode, ok := ignoreModeNames[strings.TrimSpace(keyMode[1])]
+		if !ok {
+			return nil, errors.New("unknown mode " + keyMode[1] + " please check 'fill-ignored' arg, expect skip, preserve, flag or fill")
+		}
+		modes[strings.TrimSpace(keyMode[0])] = mode
+	}
+	return modes, nil
+}
+
+// isIgnoreMarker reports whether the value is '-', the field is ignored by encoder
+func isIgnoreMarker(value string) bool {
+	return value == "-"
+}
+
+// isDashName reports whether the value is '-,' or '-,<options>', it's the explicit name '-' of field
+func isDashName(value string) bool {
+	return strings.HasPrefix(value, "-,")
+}
+
+// isUnexportedField reports whether all names of field are unexported, the embedded field isn't
+// because its fields are promoted
+func isUnexportedField(field *ast.Field) bool {
+	if len(field.Names) == 0 {
+		return false
+	}
+	for _, name := range field.Names {
+		if ast.IsExported(name.Name) {
+			return false
+		}
+	}
+	return true
+}
+
+// ignoredKey is the key of unexported field reported by ignoreFlag
+type ignoredKey struct {
+	Field *ast.Field
+	Key   string
+}
+
+// skipFill reports whether the key of field isn't filled, value is the current value of key
+func (m ignoreModes) skipFill(field *ast.Field, key, value string) bool {
+	switch m.Mode(key) {
+	case ignoreSkip, ignoreFlag:
+		return isIgnoreMarker(value) || isDashName(value) || isUnexportedField(field)
+	case ignorePreserve:
+		return isIgnoreMarker(value) || isDashName(value)
+	}
+	return false
+}
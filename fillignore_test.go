@@ -0,0 +1,50 @@
+/*
+ * Copyright 2020 bigpigeon. All rights reserved.
+ * Use of this source code is governed by a MIT style
+ * license that can be found in the LICENSE file.
+ *
+ */
+
+package main
+
+import (
+	"bytes"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"os"
+	"testing"
+)
+
+func TestParseIgnoreModes(t *testing.T) {
+	modes, err := parseIgnoreModes("json=flag, desc=fill")
+	require.NoError(t, err)
+	assert.Equal(t, ignoreFlag, modes.Mode("json"))
+	assert.Equal(t, ignoreFill, modes.Mode("desc"))
+	assert.Equal(t, ignoreSkip, modes.Mode("yaml"))
+	assert.Equal(t, ignoreFill, modes.Mode("binding"))
+
+	modes, err = parseIgnoreModes("*=preserve")
+	require.NoError(t, err)
+	assert.Equal(t, ignorePreserve, modes.Mode("json"))
+
+	_, err = parseIgnoreModes("json=ignore")
+	require.Error(t, err)
+	_, err = parseIgnoreModes("json")
+	require.Error(t, err)
+}
+
+func TestFillIgnoredFlag(t *testing.T) {
+	resetFlags()
+	initParserMode()
+	var out bytes.Buffer
+	fillIgnoredOut = &out
+	defer func() { fillIgnoredOut = os.Stderr }()
+	*fill = "json=snake(:field)"
+	*fillIgnored = "json=flag"
+	defer resetFlags()
+	src := "package main\n\ntype User struct {\n\tName   string `json:\"\"`\n\tsecret string `json:\"secret\"`\n\tcache  int    `json:\"-\"`\n}\n"
+	res, err := formatSource("ignore.go", []byte(src))
+	require.NoError(t, err)
+	assert.Equal(t, "package main\n\ntype User struct {\n\tName   string `json:\"name\"`\n\tsecret string `json:\"secret\"`\n\tcache  int    `json:\"-\"`\n}\n", string(res))
+	assert.Equal(t, "ignore.go:5:2: field secret is unexported, json has no effect\n", out.String())
+}
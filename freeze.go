@@ -0,0 +1,317 @@
+/*
+ * Copyright 2020 bigpigeon. All rights reserved.
+ * Use of this source code is governed by a MIT style
+ * license that can be found in the LICENSE file.
+ *
+ */
+
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"go/ast"
+	"go/parser"
+	"go/token"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+type freezeEmbedded int
+
+const (
+	// untagged embedded struct is promoted, embedded non-struct type is a field named by its type
+	freezeEmbeddedPromote freezeEmbedded = iota
+	// embedded type is a field named by its type unless it has inline option
+	freezeEmbeddedField
+	// don't freeze embedded field
+	freezeEmbeddedSkip
+)
+
+// freezeRule is how the encoder names the field without tag name
+type freezeRule struct {
+	defaultName func(field string) string
+	embedded    freezeEmbedded
+	inline      string
+}
+
+func sameName(s string) string { return s }
+
+var freezeRules = map[string]freezeRule{
+	"json":         {defaultName: sameName, embedded: freezeEmbeddedPromote},
+	"xml":          {defaultName: sameName, embedded: freezeEmbeddedPromote},
+	"toml":         {defaultName: sameName, embedded: freezeEmbeddedPromote},
+	"yaml":         {defaultName: strings.ToLower, embedded: freezeEmbeddedField, inline: "inline"},
+	"mapstructure": {defaultName: sameName, embedded: freezeEmbeddedField, inline: "squash"},
+	"bson":         {defaultName: strings.ToLower, embedded: freezeEmbeddedSkip},
+}
+
+// freezeOut is where the fields can't be frozen are reported
+var freezeOut io.Writer = os.Stderr
+
+// freezeKeys is set by freeze command, nil means freeze is disabled
+var freezeKeys []string
+
+type tagFreezer struct {
+	f        *ast.File
+	fs       *token.FileSet
+	filename string
+	keys     []string
+	types    map[string]*ast.TypeSpec
+	fields   []*ast.Field
+	ruleSets []map[string]tagFieldRule
+}
+
+func (s *tagFreezer) Scan() error {
+	s.types = localTypeSpecs(s.filename, s.f)
+	ast.Walk(s, s.f)
+	return nil
+}
+
+func (s *tagFreezer) Execute() error {
+	for i, field := range s.fields {
+		fieldsTagFill(nil, []*ast.Field{field}, nil, s.ruleSets[i], nil)
+	}
+	return nil
+}
+
+func (s *tagFreezer) Visit(node ast.Node) ast.Visitor {
+	cmap := ast.NewCommentMap(s.fs, node, s.f.Comments)
+	visit := newTopVisit(cmap, s.executor)
+	return visit.Visit(node)
+}
+
+func (s *tagFreezer) executor(name string, comments []*ast.CommentGroup, n *ast.StructType) {
+	if n.Fields == nil {
+		return
+	}
+	for _, field := range n.Fields.List {
+		if !fieldFilter(getFieldOrTypeName(field)) {
+			continue
+		}
+		tags := map[string]string{}
+		quote := "`"
+		if field.Tag != nil {
+			q, keyValues, err := ParseTag(field.Tag.Value)
+			if err != nil {
+				// reported by tag doctor
+				continue
+			}
+			quote = q
+			for _, kv := range keyValues {
+				tags[kv.Key] = kv.Value
+			}
+		}
+		if quote != "`" {
+			continue
+		}
+		if len(field.Names) > 1 {
+			s.reportMultipleNames(field, tags)
+			continue
+		}
+		ruleSet := map[string]tagFieldRule{}
+		for _, key := range s.keys {
+			value, exist := tags[key]
+			if fieldName, ok := s.implicitName(key, field, value); ok {
+				if exist {
+					// keep the options e.g ,omitempty
+					ruleSet[key] = constRule(fieldName + value)
+				} else {
+					ruleSet[key] = constRule(fieldName)
+				}
+			}
+		}
+		if len(ruleSet) == 0 {
+			continue
+		}
+		if field.Tag == nil {
+			field.Tag = &ast.BasicLit{Kind: token.STRING, Value: "``", ValuePos: field.Type.End()}
+		}
+		s.fields = append(s.fields, field)
+		s.ruleSets = append(s.ruleSets, ruleSet)
+	}
+}
+
+// reportMultipleNames reports the field declares multiple names, they share one tag so the names can't be pinned
+func (s *tagFreezer) reportMultipleNames(field *ast.Field, tags map[string]string) {
+	var keys []string
+	for _, key := range s.keys {
+		if _, ok := s.implicitName(key, field, tags[key]); ok {
+			keys = append(keys, key)
+		}
+	}
+	if len(keys) == 0 {
+		return
+	}
+	var names []string
+	for _, ident := range field.Names {
+		names = append(names, ident.Name)
+	}
+	msg := "freeze: field " + strings.Join(names, ", ") + " declares multiple names, " + strings.Join(keys, ",") +
+		" not frozen, declare them separately"
+	fmt.Fprintln(freezeOut, newTagDiagnostic(s.fs, field, msg).String())
+}
+
+// implicitName returns the name that encoder uses for the field without tag name,
+// false if the field is ignored, promoted or already has a name
+func (s *tagFreezer) implicitName(key string, field *ast.Field, value string) (string, bool) {
+	rule := freezeRules[key]
+	if value == "-" || (value != "" && !strings.HasPrefix(value, ",")) {
+		return "", false
+	}
+	if rule.inline != "" {
+		if v, _ := commaNamedGrammar.Parse(value); v != nil {
+			if _, ok := v.Option(rule.inline); ok {
+				return "", false
+			}
+		}
+	}
+	if len(field.Names) == 0 {
+		typeName, local := embeddedTypeName(field.Type)
+		if typeName == "" || !ast.IsExported(typeName) {
+			return "", false
+		}
+		switch rule.embedded {
+		case freezeEmbeddedSkip:
+			return "", false
+		case freezeEmbeddedPromote:
+			// unknown type may be a struct, keep it as it is
+			spec := s.types[typeName]
+			if !local || spec == nil {
+				return "", false
+			}
+			if _, isStruct := spec.Type.(*ast.StructType); isStruct {
+				return "", false
+			}
+			if _, isPointer := field.Type.(*ast.StarExpr); isPointer {
+				return "", false
+			}
+		}
+		return rule.defaultName(typeName), true
+	}
+	if key == "xml" && isXMLNameField(field) {
+		// the tag of XMLName is the element name, the struct name is used without it
+		return "", false
+	}
+	for _, ident := range field.Names {
+		if ast.IsExported(ident.Name) {
+			return rule.defaultName(ident.Name), true
+		}
+	}
+	return "", false
+}
+
+// isXMLNameField reports whether the field is XMLName xml.Name
+func isXMLNameField(field *ast.Field) bool {
+	if len(field.Names) != 1 || field.Names[0].Name != "XMLName" {
+		return false
+	}
+	sel, ok := field.Type.(*ast.SelectorExpr)
+	return ok && sel.Sel.Name == "Name"
+}
+
+// embeddedTypeName returns the type name of embedded field and whether it's declared in this package
+func embeddedTypeName(expr ast.Expr) (string, bool) {
+	switch t := expr.(type) {
+	case *ast.StarExpr:
+		return embeddedTypeName(t.X)
+	case *ast.Ident:
+		return t.Name, true
+	case *ast.SelectorExpr:
+		return t.Sel.Name, false
+	case *ast.IndexExpr:
+		return embeddedTypeName(t.X)
+	case *ast.IndexListExpr:
+		return embeddedTypeName(t.X)
+	}
+	return "", false
+}
+
+func constRule(s string) tagFieldRule {
+	return func(args *ruleFuncArgs) string {
+		return s
+	}
+}
+
+// localTypeSpecs returns the type declarations in the package of file,
+// the other files of package are read from the directory of filename
+func localTypeSpecs(filename string, file *ast.File) map[string]*ast.TypeSpec {
+	specs := map[string]*ast.TypeSpec{}
+	collect := func(f *ast.File) {
+		for _, decl := range f.Decls {
+			if gen, ok := decl.(*ast.GenDecl); ok && gen.Tok == token.TYPE {
+				for _, spec := range gen.Specs {
+					ts := spec.(*ast.TypeSpec)
+					specs[ts.Name.Name] = ts
+				}
+			}
+		}
+	}
+	for _, f := range siblingFiles(filename, file) {
+		collect(f)
+	}
+	collect(file)
+	return specs
+}
+
+// siblingFiles parses the other go files of the same package in the directory of filename,
+// nil if filename isn't a file e.g standard input
+func siblingFiles(filename string, file *ast.File) []*ast.File {
+	var files []*ast.File
+	if info, err := os.Stat(filename); err != nil || info.IsDir() {
+		return nil
+	}
+	infos, err := ioutil.ReadDir(filepath.Dir(filename))
+	if err != nil {
+		return nil
+	}
+	abs, _ := filepath.Abs(filename)
+	for _, info := range infos {
+		path := filepath.Join(filepath.Dir(filename), info.Name())
+		if !isGoFile(info) || strings.HasSuffix(info.Name(), "_test.go") {
+			continue
+		}
+		if p, _ := filepath.Abs(path); p == abs {
+			continue
+		}
+		f, err := parser.ParseFile(token.NewFileSet(), path, nil, parser.ParseComments)
+		if err != nil || f.Name.Name != file.Name.Name {
+			continue
+		}
+		files = append(files, f)
+	}
+	return files
+}
+
+func freezeMain(args []string) error {
+	flags := flag.NewFlagSet("freeze", flag.ContinueOnError)
+	keysArg := flags.String("keys", "json", "the serialization keys to freeze e.g json,yaml,bson,xml,toml,mapstructure")
+	flags.BoolVar(write, "w", *write, "write result to (source) file instead of stdout")
+	flags.BoolVar(list, "l", *list, "list files whose formatting differs from tagfmt's")
+	flags.BoolVar(doDiff, "d", *doDiff, "display diffs instead of rewriting files")
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+	freezeKeys = nil
+	for _, key := range strings.Split(*keysArg, ",") {
+		key = strings.TrimSpace(key)
+		if key == "" {
+			continue
+		}
+		if _, ok := freezeRules[key]; !ok {
+			return errors.New("unsupported key " + key + " please check 'keys' arg")
+		}
+		freezeKeys = append(freezeKeys, key)
+	}
+	defer func() { freezeKeys = nil }()
+	processArgs(flags.Args())
+	return nil
+}
+
+func newTagFreeze(f *ast.File, fs *token.FileSet, filename string, keys []string) *tagFreezer {
+	return &tagFreezer{f: f, fs: fs, filename: filename, keys: keys}
+}
@@ -0,0 +1,60 @@
+/*
+ * Copyright 2020 bigpigeon. All rights reserved.
+ * Use of this source code is governed by a MIT style
+ * license that can be found in the LICENSE file.
+ *
+ */
+
+package main
+
+import (
+	"bytes"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"os"
+	"testing"
+)
+
+func TestFreeze(t *testing.T) {
+	resetFlags()
+	initParserMode()
+	freezeKeys = []string{"json", "yaml"}
+	defer func() { freezeKeys = nil }()
+	src := "package main\n\ntype ID int\n\ntype Base struct{}\n\ntype User struct {\n\tBase\n\tID\n\tName    string `json:\",omitempty\"`\n\tSkip    string `json:\"-\" yaml:\"-\"`\n\tprivate int\n}\n"
+	res, err := formatSource("freeze.go", []byte(src))
+	require.NoError(t, err)
+	assert.Equal(t, "package main\n\ntype ID int\n\ntype Base struct{}\n\ntype User struct {\n"+
+		"\tBase    `yaml:\"base\"`\n"+
+		"\tID      `json:\"ID\"   yaml:\"id\"`\n"+
+		"\tName    string `json:\"Name,omitempty\" yaml:\"name\"`\n"+
+		"\tSkip    string `json:\"-\"              yaml:\"-\"`\n"+
+		"\tprivate int\n}\n", string(res))
+}
+
+func TestFreezeMultipleNames(t *testing.T) {
+	resetFlags()
+	initParserMode()
+	freezeKeys = []string{"json", "yaml"}
+	defer func() { freezeKeys = nil }()
+	var out bytes.Buffer
+	freezeOut = &out
+	defer func() { freezeOut = os.Stderr }()
+	src := "package main\n\ntype User struct {\n\tFirst, Last string\n\ta, b        int\n\tX, Y        int `json:\"-\" yaml:\"-\"`\n}\n"
+	res, err := formatSource("freeze.go", []byte(src))
+	require.NoError(t, err)
+	assert.Equal(t, src, string(res))
+	assert.Equal(t, "freeze.go:4:2: freeze: field First, Last declares multiple names, json,yaml not frozen, declare them separately\n", out.String())
+}
+
+func TestFreezeXMLName(t *testing.T) {
+	resetFlags()
+	initParserMode()
+	freezeKeys = []string{"xml", "json"}
+	defer func() { freezeKeys = nil }()
+	src := "package main\n\nimport \"encoding/xml\"\n\ntype User struct {\n\tXMLName xml.Name\n\tName    string\n}\n"
+	res, err := formatSource("freeze.go", []byte(src))
+	require.NoError(t, err)
+	assert.Equal(t, "package main\n\nimport \"encoding/xml\"\n\ntype User struct {\n"+
+		"\tXMLName xml.Name `json:\"XMLName\"`\n"+
+		"\tName    string   `json:\"Name\"    xml:\"Name\"`\n}\n", string(res))
+}
@@ -0,0 +1,281 @@
+/*
+ * Copyright 2020 bigpigeon. All rights reserved.
+ * Use of this source code is governed by a MIT style
+ * license that can be found in the LICENSE file.
+ *
+ */
+
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"errors"
+	"fmt"
+	"go/ast"
+	"io"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// lineRange is a closed range of line numbers (1-based)
+type lineRange struct {
+	Start int
+	End   int
+}
+
+func (r lineRange) overlap(start, end int) bool {
+	return r.Start <= end && start <= r.End
+}
+
+// lineRangeSelect returns a structRangeSelect that select the structs overlapping with the ranges
+func lineRangeSelect(ranges []lineRange) func(n *ast.StructType) bool {
+	return func(n *ast.StructType) bool {
+		start := fileSet.Position(n.Pos()).Line
+		end := fileSet.Position(n.End()).Line
+		for _, r := range ranges {
+			if r.overlap(start, end) {
+				return true
+			}
+		}
+		return false
+	}
+}
+
+// diffBaseFiles is the changed file set relative to -diff-base, nil means don't filter
+var diffBaseFiles map[string]bool
+
+func diffBaseSelect(path string) bool {
+	if diffBaseFiles == nil {
+		return true
+	}
+	abs, err := filepath.Abs(path)
+	if err != nil {
+		return false
+	}
+	return diffBaseFiles[abs]
+}
+
+func gitOutput(dir string, stdin []byte, args ...string) ([]byte, error) {
+	cmd := exec.Command("git", args...)
+	cmd.Dir = dir
+	if stdin != nil {
+		cmd.Stdin = bytes.NewReader(stdin)
+	}
+	var stderr bytes.Buffer
+	cmd.Stderr = &stderr
+	data, err := cmd.Output()
+	if err != nil {
+		msg := strings.TrimSpace(stderr.String())
+		if msg == "" {
+			msg = err.Error()
+		}
+		return nil, fmt.Errorf("git %s: %s", args[0], msg)
+	}
+	return data, nil
+}
+
+func gitTopLevel(dir string) (string, error) {
+	data, err := gitOutput(dir, nil, "rev-parse", "--show-toplevel")
+	if err != nil {
+		return "", err
+	}
+	return strings.TrimSpace(string(data)), nil
+}
+
+// gitChangedFiles returns the absolute path of files changed relative to ref,
+// with cached it returns the files changed in the index
+func gitChangedFiles(ref string, cached bool) (map[string]bool, error) {
+	top, err := gitTopLevel(".")
+	if err != nil {
+		return nil, err
+	}
+	args := []string{"diff", "--name-only", "--no-renames", "--diff-filter=ACMR"}
+	if cached {
+		args = append(args, "--cached")
+	}
+	if ref != "" {
+		args = append(args, ref)
+	}
+	data, err := gitOutput(top, nil, args...)
+	if err != nil {
+		return nil, err
+	}
+	files := map[string]bool{}
+	for _, name := range strings.Split(string(data), "\n") {
+		if name = strings.TrimSpace(name); name != "" {
+			files[filepath.Join(top, filepath.FromSlash(name))] = true
+		}
+	}
+	return files, nil
+}
+
+// gitChangedLines returns the lines of filename changed relative to ref,
+// with cached the lines come from the index version of file
+func gitChangedLines(filename, ref string, cached bool) ([]lineRange, error) {
+	args := []string{"diff", "-U0", "--no-color", "--no-ext-diff"}
+	if cached {
+		args = append(args, "--cached")
+	}
+	if ref != "" {
+		args = append(args, ref)
+	}
+	args = append(args, "--", filepath.Base(filename))
+	data, err := gitOutput(filepath.Dir(filename), nil, args...)
+	if err != nil {
+		return nil, err
+	}
+	return parseDiffHunks(data)
+}
+
+// parseDiffHunks parses the new file line ranges from unified diff hunk header
+// e.g @@ -10,2 +10,3 @@ => lines 10 to 12
+func parseDiffHunks(data []byte) ([]lineRange, error) {
+	var ranges []lineRange
+	scanner := bufio.NewScanner(bytes.NewReader(data))
+	for scanner.Scan() {
+		line := scanner.Text()
+		if !strings.HasPrefix(line, "@@ ") {
+			continue
+		}
+		fields := strings.Fields(line)
+		if len(fields) < 3 || !strings.HasPrefix(fields[2], "+") {
+			return nil, errors.New("invalid diff hunk " + line)
+		}
+		pos := strings.SplitN(fields[2][1:], ",", 2)
+		start, err := strconv.Atoi(pos[0])
+		if err != nil {
+			return nil, errors.New("invalid diff hunk " + line)
+		}
+		count := 1
+		if len(pos) == 2 {
+			count, err = strconv.Atoi(pos[1])
+			if err != nil {
+				return nil, errors.New("invalid diff hunk " + line)
+			}
+		}
+		if count == 0 {
+			// pure deletion after line start, select the lines around it
+			ranges = append(ranges, lineRange{start, start + 1})
+		} else {
+			ranges = append(ranges, lineRange{start, start + count - 1})
+		}
+	}
+	return ranges, scanner.Err()
+}
+
+// processStaged formats the staged version of go files and write the result back to the git index,
+// paths limit the files to be processed, empty paths means all staged files
+func processStaged(paths []string, out io.Writer) error {
+	top, err := gitTopLevel(".")
+	if err != nil {
+		return err
+	}
+	files, err := gitChangedFiles("", true)
+	if err != nil {
+		return err
+	}
+	var limits []string
+	for _, p := range paths {
+		abs, err := filepath.Abs(p)
+		if err != nil {
+			return err
+		}
+		limits = append(limits, abs)
+	}
+	for _, filename := range sortedKeys(files) {
+		if !strings.HasSuffix(filename, ".go") || !pathUnder(filename, limits) {
+			continue
+		}
+		if err := processStagedFile(top, filename, out); err != nil {
+			report(err)
+		}
+	}
+	return nil
+}
+
+func processStagedFile(top, filename string, out io.Writer) error {
+	rel, err := filepath.Rel(top, filename)
+	if err != nil {
+		return err
+	}
+	rel = filepath.ToSlash(rel)
+	src, err := gitOutput(top, nil, "show", ":"+rel)
+	if err != nil {
+		return err
+	}
+	ranges, err := gitChangedLines(filename, "", true)
+	if err != nil {
+		return err
+	}
+	restore, err := applyConfig(filename)
+	if err != nil {
+		return err
+	}
+	defer restore()
+	structRangeSelect = lineRangeSelect(ranges)
+	defer func() { structRangeSelect = nil }()
+
+	res, err := formatSource(filename, src)
+	if err != nil {
+		return err
+	}
+	if bytes.Equal(src, res) {
+		return nil
+	}
+	if *list {
+		fmt.Fprintln(out, filename)
+	}
+	if *doDiff {
+		data, err := diff(src, res, filename)
+		if err != nil {
+			return fmt.Errorf("computing diff: %s", err)
+		}
+		fmt.Fprintf(out, "diff -u %s %s\n", filepath.ToSlash(filename+".orig"), filepath.ToSlash(filename))
+		out.Write(data)
+	}
+	if *list || *doDiff {
+		return nil
+	}
+
+	stage, err := gitOutput(top, nil, "ls-files", "-s", "--", rel)
+	if err != nil {
+		return err
+	}
+	mode := strings.Fields(string(stage))
+	if len(mode) == 0 {
+		return errors.New("file " + rel + " is not in the index")
+	}
+	hash, err := gitOutput(top, res, "hash-object", "-w", "--stdin", "--path="+rel)
+	if err != nil {
+		return err
+	}
+	_, err = gitOutput(top, nil, "update-index", "--cacheinfo", mode[0]+","+strings.TrimSpace(string(hash))+","+rel)
+	return err
+}
+
+// pathUnder reports whether the filename is one of paths or in the directory of paths
+func pathUnder(filename string, paths []string) bool {
+	if len(paths) == 0 {
+		return true
+	}
+	for _, p := range paths {
+		if filename == p || strings.HasPrefix(filename, p+string(os.PathSeparator)) {
+			return true
+		}
+	}
+	return false
+}
+
+func sortedKeys(m map[string]bool) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
@@ -0,0 +1,45 @@
+/*
+ * Copyright 2020 bigpigeon. All rights reserved.
+ * Use of this source code is governed by a MIT style
+ * license that can be found in the LICENSE file.
+ *
+ */
+
+package main
+
+import (
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"testing"
+)
+
+func TestParseDiffHunks(t *testing.T) {
+	data := []byte(`diff --git a/api.go b/api.go
+index 1111111..2222222 100644
+--- a/api.go
++++ b/api.go
+@@ -3,0 +4,2 @@ type OrderDetail struct {
++	ID string ` + "``" + `
++	Name string ` + "``" + `
+@@ -10 +12 @@ type User struct {
+-	Name string
++	Name string ` + "``" + `
+@@ -20,3 +22,0 @@ type Order struct {
+`)
+	ranges, err := parseDiffHunks(data)
+	require.NoError(t, err)
+	assert.Equal(t, []lineRange{{4, 5}, {12, 12}, {22, 23}}, ranges)
+}
+
+func TestLineRangeSelect(t *testing.T) {
+	resetFlags()
+	initParserMode()
+	src := []byte("package main\n\ntype A struct {\n\tA  string `json:\"a\" yaml:\"a\"`\n\tAB string `json:\"ab\" yaml:\"ab\"`\n}\n\n" +
+		"type B struct {\n\tB  string `json:\"b\" yaml:\"b\"`\n\tBC string `json:\"bc\" yaml:\"bc\"`\n}\n")
+	structRangeSelect = lineRangeSelect([]lineRange{{9, 9}})
+	defer func() { structRangeSelect = nil }()
+	res, err := formatSource("range.go", src)
+	require.NoError(t, err)
+	assert.Equal(t, "package main\n\ntype A struct {\n\tA  string `json:\"a\" yaml:\"a\"`\n\tAB string `json:\"ab\" yaml:\"ab\"`\n}\n\n"+
+		"type B struct {\n\tB  string `json:\"b\"  yaml:\"b\"`\n\tBC string `json:\"bc\" yaml:\"bc\"`\n}\n", string(res))
+}
@@ -25,6 +25,7 @@ import (
 	"regexp"
 	"runtime"
 	"runtime/pprof"
+	"sort"
 	"strconv"
 	"strings"
 )
@@ -39,11 +40,31 @@ var (
 	tagSortWeight        = flag.String("sw", "", "sort struct tag keys weight e.g json=1|yaml=2|desc=-1 the higher weight, the higher the ranking, default keys weight is 0")
 	doDiff               = flag.Bool("d", false, "display diffs instead of rewriting files")
 	allErrors            = flag.Bool("e", false, "report all errors (not just the first 10 on different lines)")
-	fill                 = flag.String("f", "", "fill key and value for field e.g json=lower(_val)|yaml=snake(_val)")
+	fill                 = flag.String("f", "", "fill key and value for field e.g json=lower(_val)|yaml=snake(_val), auto means infer the convention from existing tags")
+	fillIgnored          = flag.String("fill-ignored", "", "how fill treats the fields with '-' value and unexported fields per key, modes are skip,preserve,flag,fill e.g json=flag,desc=fill, the encoder keys json,xml,yaml,toml,bson,mapstructure,gorm,db are skip by default, the others are fill")
+	explain              = flag.Bool("explain", false, "display the conventions inferred by -f auto")
+	lint                 = flag.Bool("lint", false, "report problems of tags e.g unknown or conflicting options instead of rewriting files")
+	fix                  = flag.Bool("fix", false, "apply the suggested fixes of tag problems")
+	remove               = flag.String("remove", "", "remove the keys from tags e.g json,bson, the tag becomes empty is removed")
+	allowKeys            = flag.String("allow-keys", "", "the allowed keys e.g json,yaml, the others are reported by -lint and removed by -fix")
+	pruneEmpty           = flag.Bool("prune-empty", false, "remove the keys with empty value e.g xml:\"\" and the empty tags")
+	pruneKeys            = flag.String("prune-keys", "", "only prune the empty value of the keys e.g xml,gorm")
+	renameKey            = flag.String("rename-key", "", "rename the keys and keep the values e.g form=query,mapstructure=koanf")
+	migrate              = flag.String("migrate", "", "move the option of old key to new key e.g db=gorm.column, the old key is kept if some of value can't be translated")
+	docFrom              = flag.String("doc-from", "", "write the value of the key as doc comment of the field has no comment e.g desc")
+	normalize            = flag.String("normalize", "", "normalize options in tag value, operations are space,dedup,order e.g json=space,dedup|gorm=order|*")
 	pattern              = flag.String("p", ".*", "field name with regular expression pattern")
 	inversePattern       = flag.String("P", "", "field name with inverse regular expression pattern")
 	structPattern        = flag.String("sp", ".*", "struct name with regular expression pattern")
 	inverseStructPattern = flag.String("sP", "", "struct name with inverse regular expression pattern")
+	diffBase             = flag.String("diff-base", "", "only process files and structs changed relative to the git ref e.g origin/main")
+	staged               = flag.Bool("staged", false, "only process staged structs, read from and write back to the git index")
+	lines                = flag.String("lines", "", "only process structs overlapping the line range e.g 40:75")
+	at                   = flag.String("at", "", "only process the innermost struct containing the position e.g file.go:123:5")
+	edits                = flag.String("edits", "", "display the text edits in the format instead of rewriting files, only json is supported")
+	stdinFilename        = flag.String("stdin-filename", "", "the file path of standard input, used in error messages and position")
+	watch                = flag.Bool("watch", false, "watch the paths and rewrite go files when they change")
+	watchIgnore          = flag.String("watch-ignore", "vendor|testdata", "ignored path patterns in watch mode e.g vendor|*_gen.go")
 
 	// debugging
 	cpuprofile = flag.String("cpuprofile", "", "write cpu profile to this file")
@@ -58,10 +79,30 @@ func resetFlags() {
 	*doDiff = false
 	*allErrors = false
 	*fill = ""
+	*fillIgnored = ""
+	*explain = false
+	*remove = ""
+	*allowKeys = ""
+	*pruneEmpty = false
+	*pruneKeys = ""
+	*renameKey = ""
+	*migrate = ""
+	*docFrom = ""
+	*normalize = ""
+	*lint = false
+	*fix = false
 	*pattern = ".*"
 	*inversePattern = ""
 	*structPattern = ".*"
 	*inverseStructPattern = ""
+	*diffBase = ""
+	*staged = false
+	*lines = ""
+	*at = ""
+	*edits = ""
+	*stdinFilename = ""
+	*watch = false
+	*watchIgnore = "vendor|testdata"
 	*cpuprofile = ""
 }
 
@@ -95,6 +136,15 @@ func report(err error) {
 
 func usage() {
 	fmt.Fprintf(os.Stderr, "usage: tagfmt [flags] [path ...]\n")
+	cmds := commands()
+	names := make([]string, 0, len(cmds))
+	for name := range cmds {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		fmt.Fprintf(os.Stderr, "   or: %s\n", cmds[name].Usage)
+	}
 	flag.PrintDefaults()
 }
 
@@ -127,38 +177,101 @@ func processFile(filename string, in io.Reader, out io.Writer, stdin bool) error
 		in = f
 		perm = fi.Mode().Perm()
 	}
-	if *inversePattern != "" {
-		err := selectInit(*inversePattern, true)
-		if err != nil {
-			return err
+	src, err := ioutil.ReadAll(in)
+	if err != nil {
+		return err
+	}
+
+	restore, err := applyConfig(filename)
+	if err != nil {
+		return err
+	}
+	defer restore()
+
+	selects, err := targetSelects(filename, stdin)
+	if err != nil {
+		return err
+	}
+	if len(selects) != 0 {
+		structRangeSelect = allSelect(selects)
+		defer func() { structRangeSelect = nil }()
+	}
+
+	if *lint {
+		n, err := lintFile(filename, src, out)
+		if n != 0 && exitCode == 0 {
+			exitCode = 1
 		}
-	} else {
-		err := selectInit(*pattern, false)
-		if err != nil {
-			return err
+		return err
+	}
+
+	res, err := formatSource(filename, src)
+	if err != nil {
+		return err
+	}
+
+	if *edits != "" {
+		// always answer the standard input even nothing changed
+		if stdin || !bytes.Equal(src, res) {
+			return writeEdits(out, filename, src, res)
 		}
+		return nil
 	}
 
-	if *inverseStructPattern != "" {
-		err := structSelectInit(*inverseStructPattern, true)
-		if err != nil {
-			return err
+	if !bytes.Equal(src, res) {
+		// formatting has changed
+		if *list {
+			fmt.Fprintln(out, filename)
 		}
-	} else {
-		err := structSelectInit(*structPattern, false)
-		if err != nil {
-			return err
+		if *write {
+			// make a temporary backup before overwriting original
+			bakname, err := backupFile(filename+".", src, perm)
+			if err != nil {
+				return err
+			}
+			err = ioutil.WriteFile(filename, res, perm)
+			if err != nil {
+				os.Rename(bakname, filename)
+				return err
+			}
+			err = os.Remove(bakname)
+			if err != nil {
+				return err
+			}
+		}
+		if *doDiff {
+			data, err := diff(src, res, filename)
+			if err != nil {
+				return fmt.Errorf("computing diff: %s", err)
+			}
+			fmt.Printf("diff -u %s %s\n", filepath.ToSlash(filename+".orig"), filepath.ToSlash(filename))
+			out.Write(data)
 		}
 	}
 
-	src, err := ioutil.ReadAll(in)
-	if err != nil {
-		return err
+	if !*list && !*write && !*doDiff {
+		_, err = out.Write(res)
+	}
+
+	return err
+}
+
+// formatSource runs all enabled executors over src and returns the printed result
+func formatSource(filename string, src []byte) ([]byte, error) {
+	if err := selectInitFromFlags(); err != nil {
+		return nil, err
+	}
+
+	if *docFrom != "" {
+		var err error
+		if src, err = insertDocComments(filename, src, *docFrom); err != nil {
+			return nil, err
+		}
 	}
 
 	file, err := parser.ParseFile(fileSet, filename, src, parserMode)
 	if err != nil {
-		return err
+		return nil, err
 	}
 
 	var executor []Executor
@@ -168,14 +281,49 @@ func processFile(filename string, in io.Reader, out io.Writer, stdin bool) error
 		fs: fileSet,
 	})
 
-	if *fill != "" {
-		filler, err := newTagFill(file, fileSet, *fill)
+	if *remove != "" || (*allowKeys != "" && *fix) || *pruneEmpty {
+		executor = append(executor, newTagKeyRemove(file, fileSet, *remove, *allowKeys, *pruneEmpty, *pruneKeys, *fix))
+	}
+
+	if *renameKey != "" || *migrate != "" {
+		renamer, err := newTagKeyRename(file, fileSet, *renameKey, *migrate)
 		if err != nil {
-			return err
+			return nil, err
+		}
+		executor = append(executor, renamer)
+	}
+
+	if ddlSync != nil {
+		executor = append(executor, newTagDDLSync(file, fileSet, filename, ddlSync, false))
+	}
+
+	if freezeKeys != nil {
+		executor = append(executor, newTagFreeze(file, fileSet, filename, freezeKeys))
+	}
+
+	if *fill == autoFillRule {
+		executor = append(executor, newTagAutoFill(file, fileSet, filename, *explain))
+	} else if *fill != "" {
+		filler, err := newTagFill(file, fileSet, filename, *fill, *fillIgnored)
+		if err != nil {
+			return nil, err
 		}
 		executor = append(executor, filler)
 	}
 
+	if *fix {
+		executor = append(executor, newTagValidate(file, fileSet, true))
+		executor = append(executor, newTagPolicyCheck(file, fileSet, filename, activePolicies, true))
+	}
+
+	if *normalize != "" {
+		normalizer, err := newTagNormalize(file, fileSet, *normalize)
+		if err != nil {
+			return nil, err
+		}
+		executor = append(executor, normalizer)
+	}
+
 	if *tagSort {
 
 		weights := map[string]int{}
@@ -186,12 +334,12 @@ func processFile(filename string, in io.Reader, out io.Writer, stdin bool) error
 			}
 			keyVals := strings.Split(weightStr, "=")
 			if len(keyVals) != 2 {
-				return errors.New("tagSortWeight format error please check 'sw' arg")
+				return nil, errors.New("tagSortWeight format error please check 'sw' arg")
 			}
 			key := strings.TrimSpace(keyVals[0])
 			val, err := strconv.Atoi(strings.TrimSpace(keyVals[1]))
 			if err != nil {
-				return errors.New("tagSortWeight format error please check 'sw' arg: " + err.Error())
+				return nil, errors.New("tagSortWeight format error please check 'sw' arg: " + err.Error())
 			}
 			weights[key] = val
 		}
@@ -203,13 +351,13 @@ func processFile(filename string, in io.Reader, out io.Writer, stdin bool) error
 	for _, scan := range executor {
 		err := scan.Scan()
 		if err != nil {
-			return err
+			return nil, err
 		}
 	}
 	for _, exe := range executor {
 		err := exe.Execute()
 		if err != nil {
-			return err
+			return nil, err
 		}
 	}
 
@@ -218,50 +366,13 @@ func processFile(filename string, in io.Reader, out io.Writer, stdin bool) error
 
 	err = cfg.Fprint(&buf, fileSet, file)
 	if err != nil {
-		return err
-	}
-	res := buf.Bytes()
-
-	if !bytes.Equal(src, res) {
-		// formatting has changed
-		if *list {
-			fmt.Fprintln(out, filename)
-		}
-		if *write {
-			// make a temporary backup before overwriting original
-			bakname, err := backupFile(filename+".", src, perm)
-			if err != nil {
-				return err
-			}
-			err = ioutil.WriteFile(filename, res, perm)
-			if err != nil {
-				os.Rename(bakname, filename)
-				return err
-			}
-			err = os.Remove(bakname)
-			if err != nil {
-				return err
-			}
-		}
-		if *doDiff {
-			data, err := diff(src, res, filename)
-			if err != nil {
-				return fmt.Errorf("computing diff: %s", err)
-			}
-			fmt.Printf("diff -u %s %s\n", filepath.ToSlash(filename+".orig"), filepath.ToSlash(filename))
-			out.Write(data)
-		}
+		return nil, err
 	}
-
-	if !*list && !*write && !*doDiff {
-		_, err = out.Write(res)
-	}
-
-	return err
+	return buf.Bytes(), nil
 }
 
 func visitFile(path string, f os.FileInfo, err error) error {
-	if err == nil && isGoFile(f) {
+	if err == nil && isGoFile(f) && diffBaseSelect(path) {
 		err = processFile(path, nil, os.Stdout, false)
 	}
 	// Don't complain if a file was deleted in the meantime (i.e.
@@ -288,6 +399,7 @@ func gofmtMain() {
 	flag.Usage = usage
 
 	flag.Parse()
+	recordExplicitFlags()
 
 	if *cpuprofile != "" {
 		f, err := os.Create(*cpuprofile)
@@ -303,20 +415,80 @@ func gofmtMain() {
 
 	initParserMode()
 
-	if flag.NArg() == 0 {
+	if cmd, ok := lookupCommand(flag.Args()); ok {
+		if err := cmd.Run(flag.Args()[1:]); err != nil {
+			report(err)
+		}
+		return
+	}
+
+	if *watch {
+		watchMain(flag.Args())
+		return
+	}
+
+	if *staged {
+		if err := processStaged(flag.Args(), os.Stdout); err != nil {
+			report(err)
+		}
+		return
+	}
+
+	if *diffBase != "" {
+		files, err := gitChangedFiles(*diffBase, false)
+		if err != nil {
+			report(err)
+			return
+		}
+		diffBaseFiles = files
+		defer func() { diffBaseFiles = nil }()
+		if flag.NArg() == 0 {
+			for _, path := range sortedKeys(files) {
+				if strings.HasSuffix(path, ".go") {
+					if err := processFile(path, nil, os.Stdout, false); err != nil && !os.IsNotExist(err) {
+						report(err)
+					}
+				}
+			}
+			return
+		}
+	}
+
+	if flag.NArg() == 0 && *at != "" {
+		c, err := parseCursorPosition(*at)
+		if err != nil {
+			report(err)
+			return
+		}
+		if err := processFile(c.Filename, nil, os.Stdout, false); err != nil {
+			report(err)
+		}
+		return
+	}
+
+	processArgs(flag.Args())
+}
+
+// processArgs processes the files and directories, or standard input if args is empty
+func processArgs(args []string) {
+	if len(args) == 0 {
 		if *write {
 			fmt.Fprintln(os.Stderr, "error: cannot use -w with standard input")
 			exitCode = 2
 			return
 		}
-		if err := processFile("<standard input>", os.Stdin, os.Stdout, true); err != nil {
+		filename := "<standard input>"
+		if *stdinFilename != "" {
+			filename = *stdinFilename
+		}
+		if err := processFile(filename, os.Stdin, os.Stdout, true); err != nil {
 			report(err)
 		}
 		return
 	}
 
-	for i := 0; i < flag.NArg(); i++ {
-		path := flag.Arg(i)
+	for _, arg := range args {
+		path := expandPath(arg)
 		switch dir, err := os.Stat(path); {
 		case err != nil:
 			report(err)
@@ -433,12 +605,42 @@ func backupFile(filename string, data []byte, perm os.FileMode) (string, error)
 }
 
 // change field's tag will cause the token.Pos wrong
-// so I make all token.Pos step in Scan and field's tag change in Execute
+// so I make all token.Pos step in Scan and field's tag change in Execute,
+// except the executors create or remove tags (remove, rename, ddl sync and policy fix),
+// they change tags in Scan so they must be added before the executors read the tags in Scan
 type Executor interface {
 	Scan() error
 	Execute() error
 }
 
+// selectInitFromFlags init the field and struct select with -p -P -sp -sP
+func selectInitFromFlags() error {
+	if *inversePattern != "" {
+		err := selectInit(*inversePattern, true)
+		if err != nil {
+			return err
+		}
+	} else {
+		err := selectInit(*pattern, false)
+		if err != nil {
+			return err
+		}
+	}
+
+	if *inverseStructPattern != "" {
+		err := structSelectInit(*inverseStructPattern, true)
+		if err != nil {
+			return err
+		}
+	} else {
+		err := structSelectInit(*structPattern, false)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
 var fieldFilter func(s string) bool
 
 func selectInit(expr string, inverse bool) error {
@@ -461,6 +663,9 @@ func selectInit(expr string, inverse bool) error {
 
 var structFieldSelect func(s string) bool
 
+// structRangeSelect limits executors to a part of the file, nil means select all structs
+var structRangeSelect func(n *ast.StructType) bool
+
 func structSelectInit(expr string, inverse bool) error {
 	var err error
 	selRule, err := regexp.Compile(expr)
@@ -115,6 +115,10 @@ func runTest(t *testing.T, in, out string) {
 			stdin = true
 		case "-s":
 			*tagSort = true
+		case "-fix":
+			*fix = true
+		case "-prune-empty":
+			*pruneEmpty = true
 		case "-f":
 			nextVal = func(s string) {
 				var err error
@@ -123,6 +127,14 @@ func runTest(t *testing.T, in, out string) {
 					panic("err: " + err.Error() + " str: " + s)
 				}
 			}
+		case "-normalize":
+			nextVal = func(s string) {
+				var err error
+				*normalize, err = strconv.Unquote(s)
+				if err != nil {
+					panic(err)
+				}
+			}
 		case "-p":
 			nextVal = func(s string) {
 				var err error
@@ -163,6 +175,22 @@ func runTest(t *testing.T, in, out string) {
 					panic(err)
 				}
 			}
+		case "-lines":
+			nextVal = func(s string) {
+				var err error
+				*lines, err = strconv.Unquote(s)
+				if err != nil {
+					panic(err)
+				}
+			}
+		case "-at":
+			nextVal = func(s string) {
+				var err error
+				*at, err = strconv.Unquote(s)
+				if err != nil {
+					panic(err)
+				}
+			}
 		case "-sw":
 			nextVal = func(s string) {
 				var err error
@@ -171,6 +199,62 @@ func runTest(t *testing.T, in, out string) {
 					panic(err)
 				}
 			}
+		case "-remove":
+			nextVal = func(s string) {
+				var err error
+				*remove, err = strconv.Unquote(s)
+				if err != nil {
+					panic(err)
+				}
+			}
+		case "-allow-keys":
+			nextVal = func(s string) {
+				var err error
+				*allowKeys, err = strconv.Unquote(s)
+				if err != nil {
+					panic(err)
+				}
+			}
+		case "-prune-keys":
+			nextVal = func(s string) {
+				var err error
+				*pruneKeys, err = strconv.Unquote(s)
+				if err != nil {
+					panic(err)
+				}
+			}
+		case "-rename-key":
+			nextVal = func(s string) {
+				var err error
+				*renameKey, err = strconv.Unquote(s)
+				if err != nil {
+					panic(err)
+				}
+			}
+		case "-migrate":
+			nextVal = func(s string) {
+				var err error
+				*migrate, err = strconv.Unquote(s)
+				if err != nil {
+					panic(err)
+				}
+			}
+		case "-fill-ignored":
+			nextVal = func(s string) {
+				var err error
+				*fillIgnored, err = strconv.Unquote(s)
+				if err != nil {
+					panic(err)
+				}
+			}
+		case "-doc-from":
+			nextVal = func(s string) {
+				var err error
+				*docFrom, err = strconv.Unquote(s)
+				if err != nil {
+					panic(err)
+				}
+			}
 		default:
 			t.Errorf("unrecognized flag name: %s", flag)
 		}
@@ -0,0 +1,287 @@
+/*
+ * Copyright 2020 bigpigeon. All rights reserved.
+ * Use of this source code is governed by a MIT style
+ * license that can be found in the LICENSE file.
+ *
+ */
+
+package main
+
+import (
+	"bytes"
+	"errors"
+	"flag"
+	"fmt"
+	"go/ast"
+	"go/parser"
+	"go/token"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// styleSurvey collects the tag style of existing code
+type styleSurvey struct {
+	files int
+	stats *tagStats
+	// before[a][b] is the count of tags that key a is before key b
+	before map[string]map[string]int
+	// the key sequences of tags with multiple keys
+	sequences [][]string
+	aligned   int
+	unaligned int
+}
+
+func newStyleSurvey() *styleSurvey {
+	return &styleSurvey{stats: newTagStats(), before: map[string]map[string]int{}}
+}
+
+// tagKeyOffsets returns the offsets of keys in tag literal
+func tagKeyOffsets(lit string) []int {
+	var offsets []int
+	for i := 1; i < len(lit)-1; i++ {
+		if lit[i] == ' ' {
+			continue
+		}
+		offsets = append(offsets, i)
+		// skip key:"value"
+		quote := strings.IndexByte(lit[i:], '"')
+		if quote == -1 {
+			break
+		}
+		end := findNextQuote(lit, i+quote+1, '"')
+		if end == -1 {
+			break
+		}
+		i = end
+	}
+	return offsets
+}
+
+// styleSurveyor is the executor that collects the key order and alignment of tags
+type styleSurveyor struct {
+	f      *ast.File
+	fs     *token.FileSet
+	survey *styleSurvey
+}
+
+func (s *styleSurveyor) Scan() error {
+	ast.Walk(s, s.f)
+	return nil
+}
+
+func (s *styleSurveyor) Execute() error {
+	return nil
+}
+
+func (s *styleSurveyor) Visit(node ast.Node) ast.Visitor {
+	cmap := ast.NewCommentMap(s.fs, node, s.f.Comments)
+	visit := newTopVisit(cmap, s.executor)
+	return visit.Visit(node)
+}
+
+func (s *styleSurveyor) executor(name string, comments []*ast.CommentGroup, n *ast.StructType) {
+	if n.Fields == nil {
+		return
+	}
+	var group []*ast.Field
+	preLine := 0
+	for _, field := range n.Fields.List {
+		if !fieldFilter(getFieldOrTypeName(field)) {
+			continue
+		}
+		line := s.fs.Position(field.Pos()).Line
+		// the same grouping as tag align
+		if field.Tag == nil || preLine+1 < line {
+			s.surveyAlign(group)
+			group = nil
+		}
+		preLine = line
+		if field.Tag == nil {
+			continue
+		}
+		_, keyValues, err := ParseTag(field.Tag.Value)
+		if err != nil {
+			continue
+		}
+		group = append(group, field)
+		if len(keyValues) < 2 {
+			continue
+		}
+		var keys []string
+		for i, kv := range keyValues {
+			keys = append(keys, kv.Key)
+			for _, later := range keyValues[i+1:] {
+				if s.survey.before[kv.Key] == nil {
+					s.survey.before[kv.Key] = map[string]int{}
+				}
+				s.survey.before[kv.Key][later.Key]++
+			}
+		}
+		s.survey.sequences = append(s.survey.sequences, keys)
+	}
+	s.surveyAlign(group)
+}
+
+// surveyAlign counts whether the keys of tags in group are aligned,
+// the group can't tell it if the tags are compact and the keys are at the same columns
+func (s *styleSurveyor) surveyAlign(group []*ast.Field) {
+	if len(group) < 2 {
+		return
+	}
+	columns := map[int]int{}
+	compact, aligned := true, true
+	for _, field := range group {
+		_, keyValues, _ := ParseTag(field.Tag.Value)
+		var raws []string
+		for _, kv := range keyValues {
+			raws = append(raws, kv.String())
+		}
+		if field.Tag.Value[1:len(field.Tag.Value)-1] != strings.Join(raws, " ") {
+			compact = false
+		}
+		start := s.fs.Position(field.Tag.Pos()).Column
+		for i, offset := range tagKeyOffsets(field.Tag.Value) {
+			if column, ok := columns[i]; ok && column != start+offset {
+				aligned = false
+			}
+			columns[i] = start + offset
+		}
+	}
+	if !aligned {
+		s.survey.unaligned++
+	} else if !compact {
+		s.survey.aligned++
+	}
+}
+
+func (s *styleSurvey) addFile(fs *token.FileSet, filename string, src []byte) error {
+	file, err := parser.ParseFile(fs, filename, src, parserMode)
+	if err != nil {
+		return err
+	}
+	s.files++
+	collector := &statsCollector{f: file, fs: fs, pkg: filepath.Dir(filename) + ":" + file.Name.Name, stats: s.stats}
+	if err := collector.Scan(); err != nil {
+		return err
+	}
+	surveyor := &styleSurveyor{f: file, fs: fs, survey: s}
+	return surveyor.Scan()
+}
+
+// keyOrder returns the keys ordered by how often they are before the others
+func (s *styleSurvey) keyOrder(keys []*tagKeyStats) []string {
+	wins := map[string]int{}
+	fields := map[string]int{}
+	var order []string
+	for _, a := range keys {
+		order = append(order, a.Key)
+		fields[a.Key] = a.Fields
+		for _, b := range keys {
+			if s.before[a.Key][b.Key] > s.before[b.Key][a.Key] {
+				wins[a.Key]++
+			}
+		}
+	}
+	sort.SliceStable(order, func(i, j int) bool {
+		if wins[order[i]] != wins[order[j]] {
+			return wins[order[i]] > wins[order[j]]
+		}
+		return fields[order[i]] > fields[order[j]]
+	})
+	return order
+}
+
+// sortedSequences returns the count of tags already sorted by order
+func (s *styleSurvey) sortedSequences(order []string) int {
+	rank := map[string]int{}
+	for i, key := range order {
+		rank[key] = i
+	}
+	count := 0
+	for _, keys := range s.sequences {
+		if sort.SliceIsSorted(keys, func(i, j int) bool { return rank[keys[i]] < rank[keys[j]] }) {
+			count++
+		}
+	}
+	return count
+}
+
+// config returns the config reproduces the style of survey
+func (s *styleSurvey) config() string {
+	var buf bytes.Buffer
+	keys := s.stats.result()
+	fmt.Fprintf(&buf, "# generated by tagfmt init from %d files\n", s.files)
+	var usage []string
+	for _, ks := range keys {
+		usage = append(usage, fmt.Sprintf("%s %d", ks.Key, ks.Fields))
+	}
+	fmt.Fprintf(&buf, "# keys: %s\n\n", strings.Join(usage, ", "))
+
+	fmt.Fprintf(&buf, "# %d groups of tags are aligned, %d are not\n", s.aligned, s.unaligned)
+	fmt.Fprintf(&buf, "a = %t\n\n", s.aligned >= s.unaligned)
+
+	order := s.keyOrder(keys)
+	sorted := s.sortedSequences(order)
+	fmt.Fprintf(&buf, "# %d of %d tags with multiple keys follow the order\n", sorted, len(s.sequences))
+	if len(s.sequences) != 0 && sorted*2 >= len(s.sequences) {
+		fmt.Fprintf(&buf, "s = true\nso = %s\n\n", strings.Join(order, "|"))
+	} else {
+		fmt.Fprintf(&buf, "s = false\n# so = %s\n\n", strings.Join(order, "|"))
+	}
+
+	var conventions []string
+	for _, ks := range keys {
+		if ks.Majority != "" {
+			conventions = append(conventions, ks.Key+" "+ks.Majority)
+		}
+	}
+	if len(conventions) != 0 {
+		fmt.Fprintf(&buf, "# naming conventions: %s\n", strings.Join(conventions, ", "))
+		fmt.Fprintf(&buf, "# fill the missing keys following them\n# f = auto\n")
+	}
+	return buf.String()
+}
+
+func initMain(args []string) error {
+	flags := flag.NewFlagSet("init", flag.ContinueOnError)
+	force := flags.Bool("force", false, "overwrite the existing config")
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+	dir := "."
+	if flags.NArg() > 1 {
+		return errors.New("init accepts only one directory")
+	} else if flags.NArg() == 1 {
+		dir = flags.Arg(0)
+	}
+	path := filepath.Join(dir, configName)
+	if _, err := os.Stat(path); err == nil && !*force {
+		return errors.New(path + " already exists, use -force to overwrite it")
+	}
+	if err := selectInitFromFlags(); err != nil {
+		return err
+	}
+	filenames, err := goFiles([]string{filepath.Join(dir, "...")})
+	if err != nil {
+		return err
+	}
+	survey := newStyleSurvey()
+	fs := token.NewFileSet()
+	for _, filename := range filenames {
+		src, err := ioutil.ReadFile(filename)
+		if err == nil {
+			err = survey.addFile(fs, filename, src)
+		}
+		if err != nil {
+			report(err)
+		}
+	}
+	if err := ioutil.WriteFile(path, []byte(survey.config()), 0644); err != nil {
+		return err
+	}
+	fmt.Println("write", path)
+	return nil
+}
@@ -0,0 +1,36 @@
+/*
+ * Copyright 2020 bigpigeon. All rights reserved.
+ * Use of this source code is governed by a MIT style
+ * license that can be found in the LICENSE file.
+ *
+ */
+
+package main
+
+import (
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go/token"
+	"testing"
+)
+
+func TestStyleSurveyConfig(t *testing.T) {
+	resetFlags()
+	initParserMode()
+	require.NoError(t, selectInitFromFlags())
+	src := "package main\n\ntype User struct {\n" +
+		"\tID       int    `json:\"id\"        yaml:\"id\"`\n" +
+		"\tUserName string `json:\"user_name\" yaml:\"user_name\" gorm:\"column:user_name\"`\n" +
+		"\tEmail    string `yaml:\"email\"     json:\"email\"`\n}\n\n" +
+		"type Order struct {\n\tID   int `json:\"id\" db:\"id\"`\n\tName string `json:\"name\" db:\"name\"`\n}\n"
+	survey := newStyleSurvey()
+	require.NoError(t, survey.addFile(token.NewFileSet(), "survey.go", []byte(src)))
+	assert.Equal(t, "# generated by tagfmt init from 1 files\n"+
+		"# keys: json 5, yaml 3, db 2, gorm 1\n\n"+
+		"# 1 groups of tags are aligned, 1 are not\n"+
+		"a = true\n\n"+
+		"# 4 of 5 tags with multiple keys follow the order\n"+
+		"s = true\nso = json|yaml|db|gorm\n\n"+
+		"# naming conventions: json snake, yaml snake, db lower, gorm snake\n"+
+		"# fill the missing keys following them\n# f = auto\n", survey.config())
+}
@@ -6,6 +6,8 @@
 
 package main
 
+import "strings"
+
 type KeyValue struct {
 	Key   string
 	quote string
@@ -58,7 +60,7 @@ func ParseTag(tag string) (quote string, keyValues []KeyValue, err error) {
 		i++
 		var quoteLen int
 		if i >= len(tag) || tag[i] != '"' {
-			if tag[i] != '\\' || i+1 >= len(tag) || tag[i+1] != '"' {
+			if i >= len(tag) || tag[i] != '\\' || i+1 >= len(tag) || tag[i+1] != '"' {
 				return "", nil, ErrInvalidTag
 			}
 			tag = tag[i:]
@@ -73,6 +75,10 @@ func ParseTag(tag string) (quote string, keyValues []KeyValue, err error) {
 		// Scan quoted string to find value.
 
 		for i < len(tag) && tag[i] != '"' {
+			// the value of raw string tag is quoted by go syntax, skip the escaped char like reflect
+			if quoteLen == 1 && tag[i] == '\\' {
+				i++
+			}
 			i++
 		}
 		if quoteLen == 2 && tag[i-1] != '\\' {
@@ -93,3 +99,28 @@ func ParseTag(tag string) (quote string, keyValues []KeyValue, err error) {
 	}
 	return
 }
+
+// joinTag returns the tag literal of key values
+func joinTag(quote string, keyValues []KeyValue) string {
+	var keyValuesRaw []string
+	for _, kv := range keyValues {
+		keyValuesRaw = append(keyValuesRaw, kv.String())
+	}
+	return quote + strings.Join(keyValuesRaw, " ") + quote
+}
+
+// removeTagKeys removes the keys from tag literal, returns the new literal and
+// whether there is no key left
+func removeTagKeys(tag string, keys map[string]bool) (string, bool, error) {
+	quote, keyValues, err := ParseTag(tag)
+	if err != nil {
+		return "", false, err
+	}
+	var left []KeyValue
+	for _, kv := range keyValues {
+		if !keys[kv.Key] {
+			left = append(left, kv)
+		}
+	}
+	return joinTag(quote, left), len(left) == 0, nil
+}
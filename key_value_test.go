@@ -22,3 +22,18 @@ func TestKeyValueParse(t *testing.T) {
 	require.NoError(t, err)
 	t.Log("quote ", quote, "kv", kv)
 }
+
+func TestKeyValueParseEscaped(t *testing.T) {
+	_, kv, err := ParseTag("`desc:\"say \\\"hi\\\" \\x60ok\\x60\" json:\"name\"`")
+	require.NoError(t, err)
+	require.Len(t, kv, 2)
+	require.Equal(t, `say \"hi\" \x60ok\x60`, kv[0].Value)
+	require.Equal(t, "name", kv[1].Value)
+}
+
+func TestKeyValueParseIncomplete(t *testing.T) {
+	for _, tag := range []string{"`json:`", "`json`", "`json:\"name`", "`json:\"name\" yaml:`"} {
+		_, _, err := ParseTag(tag)
+		require.Equal(t, ErrInvalidTag, err, tag)
+	}
+}
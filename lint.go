@@ -0,0 +1,145 @@
+/*
+ * Copyright 2020 bigpigeon. All rights reserved.
+ * Use of this source code is governed by a MIT style
+ * license that can be found in the LICENSE file.
+ *
+ */
+
+package main
+
+import (
+	"fmt"
+	"go/ast"
+	"go/parser"
+	"go/token"
+	"io"
+	"sort"
+	"strings"
+)
+
+// tagDiagnostic is a problem found in field tag, Fix is the suggested tag literal if not empty
+type tagDiagnostic struct {
+	Pos      token.Position
+	End      token.Position
+	Msg      string
+	Fix      string
+	FixTitle string
+	// Error is the problem makes the tag broken or violates the policy, the others are warnings
+	Error bool
+}
+
+func (d tagDiagnostic) String() string {
+	return d.Pos.String() + ": " + d.Msg
+}
+
+func newTagDiagnostic(fs *token.FileSet, n ast.Node, msg string) tagDiagnostic {
+	return tagDiagnostic{
+		Pos: fs.Position(n.Pos()),
+		End: fs.Position(n.End()),
+		Msg: msg,
+	}
+}
+
+// lintSource returns all diagnostics of the source
+func lintSource(filename string, src []byte) ([]tagDiagnostic, error) {
+	if err := selectInitFromFlags(); err != nil {
+		return nil, err
+	}
+	file, err := parser.ParseFile(fileSet, filename, src, parserMode)
+	if err != nil {
+		return nil, err
+	}
+	doctor := &tagDoctor{f: file, fs: fileSet}
+	// the error is reported as diagnostics
+	doctor.Scan()
+	validator := newTagValidate(file, fileSet, false)
+	validator.Scan()
+	policyChecker := newTagPolicyCheck(file, fileSet, filename, activePolicies, false)
+	policyChecker.Scan()
+	remover := newTagKeyRemove(file, fileSet, "", *allowKeys, false, "", false)
+	remover.Scan()
+	diagnostics := append(doctor.diagnostics, validator.diagnostics...)
+	diagnostics = append(diagnostics, policyChecker.diagnostics...)
+	diagnostics = append(diagnostics, remover.diagnostics...)
+	sort.SliceStable(diagnostics, func(i, j int) bool {
+		return diagnostics[i].Pos.Offset < diagnostics[j].Pos.Offset
+	})
+	return diagnostics, nil
+}
+
+// lintFile prints the diagnostics of source, returns the number of diagnostics
+func lintFile(filename string, src []byte, out io.Writer) (int, error) {
+	diagnostics, err := lintSource(filename, src)
+	if err != nil {
+		return 0, err
+	}
+	for _, diag := range diagnostics {
+		fmt.Fprintln(out, diag.String())
+	}
+	return len(diagnostics), nil
+}
+
+// repairTag try to fix the common mistake of tag literal,
+// e.g `json:name yaml: 'name'` => `json:"name" yaml:"name"`
+func repairTag(tag string) (string, bool) {
+	if len(tag) < 2 || tag[0] != '`' || tag[len(tag)-1] != '`' {
+		return "", false
+	}
+	s := tag[1 : len(tag)-1]
+	var keyValues []string
+	for i := 0; i < len(s); {
+		for i < len(s) && s[i] == ' ' {
+			i++
+		}
+		if i >= len(s) {
+			break
+		}
+		start := i
+		for i < len(s) && s[i] > ' ' && s[i] != ':' && s[i] != '=' && s[i] != '"' && s[i] != '\'' {
+			i++
+		}
+		key := s[start:i]
+		if key == "" {
+			return "", false
+		}
+		for i < len(s) && s[i] == ' ' {
+			i++
+		}
+		if i >= len(s) || (s[i] != ':' && s[i] != '=') {
+			return "", false
+		}
+		i++
+		for i < len(s) && s[i] == ' ' {
+			i++
+		}
+		var value string
+		if i < len(s) && (s[i] == '"' || s[i] == '\'') {
+			quote := s[i]
+			i++
+			end := strings.IndexByte(s[i:], quote)
+			if end == -1 {
+				// unclosed quote, the value is the rest of the tag
+				value = s[i:]
+				i = len(s)
+			} else {
+				value = s[i : i+end]
+				i += end + 1
+			}
+		} else {
+			start := i
+			for i < len(s) && s[i] != ' ' {
+				i++
+			}
+			value = s[start:i]
+		}
+		if strings.ContainsAny(value, "\"`") {
+			return "", false
+		}
+		keyValues = append(keyValues, KeyValue{Key: key, Value: value, quote: "`"}.String())
+	}
+	fixed := "`" + strings.Join(keyValues, " ") + "`"
+	if _, _, err := ParseTag(fixed); err != nil || fixed == tag {
+		return "", false
+	}
+	return fixed, true
+}
@@ -0,0 +1,47 @@
+/*
+ * Copyright 2020 bigpigeon. All rights reserved.
+ * Use of this source code is governed by a MIT style
+ * license that can be found in the LICENSE file.
+ *
+ */
+
+package main
+
+import (
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"testing"
+)
+
+func TestRepairTag(t *testing.T) {
+	for tag, expected := range map[string]string{
+		"`json:name yaml: 'name'`":    "`json:\"name\" yaml:\"name\"`",
+		"`json:\"name,omitempty`":     "`json:\"name,omitempty\"`",
+		"`json = \"name\"`":           "`json:\"name\"`",
+		"`gorm:\"type:varchar(64)\"`": "",
+		"`json`":                      "",
+	} {
+		fixed, ok := repairTag(tag)
+		assert.Equal(t, expected != "", ok, tag)
+		assert.Equal(t, expected, fixed, tag)
+	}
+}
+
+func TestLintSource(t *testing.T) {
+	resetFlags()
+	initParserMode()
+	src := "package main\n\ntype User struct {\n\tID   int    `json:\"id,omitempy,omitempy\"`\n\tName string `xml:\"name,attr,cdata\" json:name`\n}\n"
+	diagnostics, err := lintSource("lint.go", []byte(src))
+	require.NoError(t, err)
+	var messages []string
+	for _, diag := range diagnostics {
+		messages = append(messages, diag.String())
+	}
+	assert.Equal(t, []string{
+		`lint.go:4:14: json: unknown option "omitempy", did you mean "omitempty"?`,
+		`lint.go:4:14: json: repeated option "omitempy"`,
+		`lint.go:5:14: malformed tag: Invalid tag`,
+	}, messages)
+	assert.Equal(t, "`json:\"id,omitempty,omitempy\"`", diagnostics[0].Fix)
+	assert.Equal(t, "`xml:\"name,attr,cdata\" json:\"name\"`", diagnostics[2].Fix)
+}
@@ -0,0 +1,116 @@
+/*
+ * Copyright 2020 bigpigeon. All rights reserved.
+ * Use of this source code is governed by a MIT style
+ * license that can be found in the LICENSE file.
+ *
+ */
+
+package main
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"path/filepath"
+	"strings"
+)
+
+// lookupTables is the loaded mapping files, the path is key
+var lookupTables = map[string]map[string]string{}
+
+// lookupBase is the directory the relative mapping files are resolved against, it's the directory of
+// config sets the fill rule or the directory of source file, empty means working directory
+var lookupBase string
+
+// loadLookupTable loads the mapping file, json file is an object of strings, the nested object
+// is flattened by joining keys with '.', e.g {"User": {"ID": "user_id"}} is User.ID => user_id,
+// csv file uses the first column as key and the second as value
+func loadLookupTable(path string) (map[string]string, error) {
+	if table, ok := lookupTables[path]; ok {
+		return table, nil
+	}
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	table := map[string]string{}
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".json":
+		var obj map[string]interface{}
+		if err := json.Unmarshal(data, &obj); err != nil {
+			return nil, fmt.Errorf("%s: %s", path, err)
+		}
+		if err := flattenLookup(table, "", obj); err != nil {
+			return nil, fmt.Errorf("%s: %s", path, err)
+		}
+	case ".csv":
+		reader := csv.NewReader(strings.NewReader(string(data)))
+		reader.FieldsPerRecord = -1
+		reader.TrimLeadingSpace = true
+		records, err := reader.ReadAll()
+		if err != nil {
+			return nil, fmt.Errorf("%s: %s", path, err)
+		}
+		for i, record := range records {
+			if len(record) < 2 {
+				return nil, fmt.Errorf("%s:%d: expect key and value columns", path, i+1)
+			}
+			table[record[0]] = record[1]
+		}
+	default:
+		return nil, errors.New("unsupported lookup file " + path + ", expect .json or .csv")
+	}
+	lookupTables[path] = table
+	return table, nil
+}
+
+func flattenLookup(table map[string]string, prefix string, obj map[string]interface{}) error {
+	for key, value := range obj {
+		if prefix != "" {
+			key = prefix + "." + key
+		}
+		switch v := value.(type) {
+		case string:
+			table[key] = v
+		case map[string]interface{}:
+			if err := flattenLookup(table, key, v); err != nil {
+				return err
+			}
+		default:
+			return errors.New("the value of " + key + " must be string or object")
+		}
+	}
+	return nil
+}
+
+// parseLookupRule parses the args of lookup('file', key), the value is empty if key isn't in file
+func parseLookupRule(argsStr string) (tagFieldRule, error) {
+	args, err := splitWithoutQuote(argsStr, ',')
+	if err != nil {
+		return nil, err
+	}
+	if len(args) != 2 {
+		return nil, errors.New("args number wrong")
+	}
+	path := strings.TrimSpace(args[0])
+	if len(path) < 2 || (path[0] != '\'' && path[0] != '"') || path[len(path)-1] != path[0] {
+		return nil, errors.New("the file of lookup must be quoted string")
+	}
+	file := path[1 : len(path)-1]
+	if lookupBase != "" && !filepath.IsAbs(file) {
+		file = filepath.Join(lookupBase, file)
+	}
+	table, err := loadLookupTable(file)
+	if err != nil {
+		return nil, err
+	}
+	keyRule, err := parseFieldMultiRule(args[1], 1)
+	if err != nil {
+		return nil, err
+	}
+	return func(args *ruleFuncArgs) (newTagName string) {
+		return table[keyRule[0](args)]
+	}, nil
+}
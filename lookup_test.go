@@ -0,0 +1,80 @@
+/*
+ * Copyright 2020 bigpigeon. All rights reserved.
+ * Use of this source code is governed by a MIT style
+ * license that can be found in the LICENSE file.
+ *
+ */
+
+package main
+
+import (
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go/ast"
+	"go/token"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestLookupRule(t *testing.T) {
+	dir, err := ioutil.TempDir("", "tagfmt-lookup")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+	csvPath := filepath.Join(dir, "columns.csv")
+	require.NoError(t, ioutil.WriteFile(csvPath, []byte("User.ID,u_id\nUser.Name, uname\n"), 0644))
+	defer func() { lookupTables = map[string]map[string]string{} }()
+
+	rules, err := parseFieldRule("db=or(lookup('" + csvPath + "', :struct+'.'+:field), snake(:field))")
+	require.NoError(t, err)
+	args := func(structName, field string) *ruleFuncArgs {
+		return newRuleArgs(&structScope{Name: structName}, &ast.Field{Names: []*ast.Ident{{Name: field}}}, "db", "")
+	}
+	assert.Equal(t, "u_id", rules["db"](args("User", "ID")))
+	assert.Equal(t, "uname", rules["db"](args("User", "Name")))
+	assert.Equal(t, "created_at", rules["db"](args("User", "CreatedAt")))
+
+	_, err = parseFieldRule("db=lookup('" + filepath.Join(dir, "columns.txt") + "', :field)")
+	assert.Error(t, err)
+	_, err = parseFieldRule("db=lookup(:field, :field)")
+	assert.Error(t, err)
+}
+
+func TestLookupRuleBase(t *testing.T) {
+	resetFlags()
+	initParserMode()
+	dir, err := ioutil.TempDir("", "tagfmt-lookup")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+	configCache = map[string]*tagConfig{}
+	defer func() {
+		configCache = map[string]*tagConfig{}
+		lookupTables = map[string]map[string]string{}
+	}()
+	require.NoError(t, os.MkdirAll(filepath.Join(dir, "api"), 0755))
+	require.NoError(t, os.MkdirAll(filepath.Join(dir, "model"), 0755))
+	require.NoError(t, ioutil.WriteFile(filepath.Join(dir, configName), []byte("f = \"db=lookup('columns.csv', :struct+'.'+:field)\"\n"), 0644))
+	require.NoError(t, ioutil.WriteFile(filepath.Join(dir, "columns.csv"), []byte("User.ID,u_id\n"), 0644))
+	require.NoError(t, ioutil.WriteFile(filepath.Join(dir, "model", "columns.csv"), []byte("User.ID,user_id\n"), 0644))
+	src := []byte("package api\n\ntype User struct {\n\tID int `json:\"id\"`\n}\n")
+
+	// the file is relative to config
+	filename := filepath.Join(dir, "api", "user.go")
+	restore, err := applyConfig(filename)
+	require.NoError(t, err)
+	res, err := formatSource(filename, src)
+	restore()
+	require.NoError(t, err)
+	assert.Equal(t, "package api\n\ntype User struct {\n\tID int `json:\"id\" db:\"u_id\"`\n}\n", string(res))
+	assert.Equal(t, "", lookupBase)
+
+	// the rule of command line, the file is relative to source file
+	resetFlags()
+	*fill = "db=lookup('columns.csv', :struct+'.'+:field)"
+	fileSet = token.NewFileSet()
+	res, err = formatSource(filepath.Join(dir, "model", "user.go"), src)
+	require.NoError(t, err)
+	assert.Equal(t, "package api\n\ntype User struct {\n\tID int `json:\"id\" db:\"user_id\"`\n}\n", string(res))
+	assert.Equal(t, "", lookupBase)
+}
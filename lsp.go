@@ -0,0 +1,472 @@
+/*
+ * Copyright 2020 bigpigeon. All rights reserved.
+ * Use of this source code is governed by a MIT style
+ * license that can be found in the LICENSE file.
+ *
+ */
+
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"go/ast"
+	"go/token"
+	"io"
+	"net/url"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strconv"
+	"strings"
+	"unicode/utf16"
+	"unicode/utf8"
+)
+
+// the fill rule of code action when -f is not specified
+const lspDefaultFill = "json=or(:tag,snake(:field))"
+
+type lspMessage struct {
+	JSONRPC string           `json:"jsonrpc"`
+	ID      *json.RawMessage `json:"id,omitempty"`
+	Method  string           `json:"method"`
+	Params  json.RawMessage  `json:"params"`
+}
+
+type lspResponse struct {
+	JSONRPC string           `json:"jsonrpc"`
+	ID      *json.RawMessage `json:"id"`
+	Result  interface{}      `json:"result"`
+}
+
+type lspErrorResponse struct {
+	JSONRPC string           `json:"jsonrpc"`
+	ID      *json.RawMessage `json:"id"`
+	Error   lspError         `json:"error"`
+}
+
+type lspError struct {
+	Code    int    `json:"code"`
+	Message string `json:"message"`
+}
+
+type lspNotification struct {
+	JSONRPC string      `json:"jsonrpc"`
+	Method  string      `json:"method"`
+	Params  interface{} `json:"params"`
+}
+
+type lspPosition struct {
+	Line      int `json:"line"`
+	Character int `json:"character"`
+}
+
+type lspRange struct {
+	Start lspPosition `json:"start"`
+	End   lspPosition `json:"end"`
+}
+
+type lspTextEdit struct {
+	Range   lspRange `json:"range"`
+	NewText string   `json:"newText"`
+}
+
+type lspDiagnostic struct {
+	Range    lspRange `json:"range"`
+	Severity int      `json:"severity"`
+	Source   string   `json:"source"`
+	Message  string   `json:"message"`
+}
+
+type lspWorkspaceEdit struct {
+	Changes map[string][]lspTextEdit `json:"changes"`
+}
+
+type lspCodeAction struct {
+	Title       string           `json:"title"`
+	Kind        string           `json:"kind"`
+	Diagnostics []lspDiagnostic  `json:"diagnostics,omitempty"`
+	Edit        lspWorkspaceEdit `json:"edit"`
+}
+
+type lspTextDocument struct {
+	URI  string `json:"uri"`
+	Text string `json:"text"`
+}
+
+type lspDocumentParams struct {
+	TextDocument   lspTextDocument `json:"textDocument"`
+	ContentChanges []struct {
+		Text string `json:"text"`
+	} `json:"contentChanges"`
+	Range lspRange `json:"range"`
+}
+
+type lspServer struct {
+	in   *bufio.Reader
+	out  io.Writer
+	docs map[string][]byte
+}
+
+func newLspServer(in io.Reader, out io.Writer) *lspServer {
+	return &lspServer{
+		in:   bufio.NewReader(in),
+		out:  out,
+		docs: map[string][]byte{},
+	}
+}
+
+func lspMain(args []string) error {
+	if len(args) != 0 {
+		return errors.New("lsp command doesn't accept any arguments")
+	}
+	return newLspServer(os.Stdin, os.Stdout).serve()
+}
+
+func (s *lspServer) serve() error {
+	for {
+		msg, err := s.read()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		if msg.Method == "exit" {
+			return nil
+		}
+		// every message use a new file set, avoid it growing in long-running process
+		fileSet = token.NewFileSet()
+		// the config and lookup files may be edited
+		configCache = map[string]*tagConfig{}
+		lookupTables = map[string]map[string]string{}
+		result, err := s.safeHandle(msg)
+		if msg.ID == nil {
+			if err != nil {
+				fmt.Fprintln(os.Stderr, "tagfmt lsp:", err)
+			}
+			continue
+		}
+		if err != nil {
+			err = s.write(lspErrorResponse{JSONRPC: "2.0", ID: msg.ID, Error: lspError{Code: -32603, Message: err.Error()}})
+		} else {
+			err = s.write(lspResponse{JSONRPC: "2.0", ID: msg.ID, Result: result})
+		}
+		if err != nil {
+			return err
+		}
+	}
+}
+
+func (s *lspServer) read() (*lspMessage, error) {
+	length := -1
+	for {
+		line, err := s.in.ReadString('\n')
+		if err != nil {
+			return nil, err
+		}
+		line = strings.TrimSpace(line)
+		if line == "" {
+			break
+		}
+		if strings.HasPrefix(strings.ToLower(line), "content-length:") {
+			length, err = strconv.Atoi(strings.TrimSpace(line[len("content-length:"):]))
+			if err != nil {
+				return nil, errors.New("invalid header " + line)
+			}
+		}
+	}
+	if length < 0 {
+		return nil, errors.New("missing Content-Length header")
+	}
+	data := make([]byte, length)
+	if _, err := io.ReadFull(s.in, data); err != nil {
+		return nil, err
+	}
+	var msg lspMessage
+	if err := json.Unmarshal(data, &msg); err != nil {
+		return nil, err
+	}
+	return &msg, nil
+}
+
+func (s *lspServer) write(v interface{}) error {
+	data, err := json.Marshal(v)
+	if err != nil {
+		return err
+	}
+	_, err = fmt.Fprintf(s.out, "Content-Length: %d\r\n\r\n%s", len(data), data)
+	return err
+}
+
+// safeHandle calls handle, the panic is returned as error so a bad message doesn't stop the server
+func (s *lspServer) safeHandle(msg *lspMessage) (result interface{}, err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			result, err = nil, fmt.Errorf("internal error in %s: %v", msg.Method, r)
+		}
+	}()
+	return s.handle(msg)
+}
+
+func (s *lspServer) handle(msg *lspMessage) (interface{}, error) {
+	var params lspDocumentParams
+	if len(msg.Params) != 0 && msg.Method != "initialize" {
+		if err := json.Unmarshal(msg.Params, &params); err != nil {
+			return nil, err
+		}
+	}
+	uri := params.TextDocument.URI
+	switch msg.Method {
+	case "initialize":
+		return map[string]interface{}{
+			"capabilities": map[string]interface{}{
+				"textDocumentSync":                1, // full document sync
+				"documentFormattingProvider":      true,
+				"documentRangeFormattingProvider": true,
+				"codeActionProvider":              true,
+			},
+			"serverInfo": map[string]string{"name": "tagfmt"},
+		}, nil
+	case "shutdown":
+		return nil, nil
+	case "textDocument/didOpen":
+		s.docs[uri] = []byte(params.TextDocument.Text)
+		return nil, s.publishDiagnostics(uri)
+	case "textDocument/didChange":
+		if n := len(params.ContentChanges); n != 0 {
+			s.docs[uri] = []byte(params.ContentChanges[n-1].Text)
+		}
+		return nil, s.publishDiagnostics(uri)
+	case "textDocument/didClose":
+		delete(s.docs, uri)
+		return nil, s.write(lspNotification{JSONRPC: "2.0", Method: "textDocument/publishDiagnostics",
+			Params: map[string]interface{}{"uri": uri, "diagnostics": []lspDiagnostic{}}})
+	case "textDocument/formatting":
+		return s.format(uri, nil, nil)
+	case "textDocument/rangeFormatting":
+		src := s.docs[uri]
+		return s.format(uri, lspRangeSelect(src, params.Range), nil)
+	case "textDocument/codeAction":
+		return s.codeActions(uri, params.Range)
+	}
+	if msg.ID != nil && !strings.HasPrefix(msg.Method, "$/") {
+		return nil, errors.New("method not supported " + msg.Method)
+	}
+	return nil, nil
+}
+
+// format returns the edits of document formatted with current flags,
+// sel limits the structs to be formatted and setup can modify the flags temporarily
+func (s *lspServer) format(uri string, sel func(n *ast.StructType) bool, setup func()) ([]lspTextEdit, error) {
+	src, ok := s.docs[uri]
+	if !ok {
+		return nil, errors.New("document not opened " + uri)
+	}
+	restore, err := applyConfig(lspFilename(uri))
+	if err != nil {
+		return nil, err
+	}
+	defer restore()
+	oldFill, oldSort, oldAlign := *fill, *tagSort, *align
+	defer func() {
+		*fill, *tagSort, *align = oldFill, oldSort, oldAlign
+		structRangeSelect = nil
+	}()
+	if setup != nil {
+		setup()
+	}
+	structRangeSelect = sel
+	res, err := formatSource(lspFilename(uri), src)
+	if err != nil {
+		return nil, err
+	}
+	result := []lspTextEdit{}
+	for _, edit := range computeEdits(src, res) {
+		endLine, endCol := offsetLineColumn(src, edit.EndOffset)
+		result = append(result, lspTextEdit{
+			Range: lspRange{
+				Start: lspPositionOf(src, edit.StartLine, edit.StartCol),
+				End:   lspPositionOf(src, endLine, endCol),
+			},
+			NewText: edit.NewText,
+		})
+	}
+	return result, nil
+}
+
+func (s *lspServer) codeActions(uri string, r lspRange) ([]lspCodeAction, error) {
+	src, ok := s.docs[uri]
+	if !ok {
+		return nil, errors.New("document not opened " + uri)
+	}
+	restore, err := applyConfig(lspFilename(uri))
+	if err != nil {
+		return nil, err
+	}
+	defer restore()
+	actions := []lspCodeAction{}
+	diagnostics, err := lintSource(lspFilename(uri), src)
+	if err != nil {
+		// syntax error, nothing to do
+		return actions, nil
+	}
+	for _, diag := range diagnostics {
+		if diag.Fix == "" || diag.Pos.Line-1 > r.End.Line || diag.End.Line-1 < r.Start.Line {
+			continue
+		}
+		edit := lspTextEdit{Range: lspDiagnosticRange(src, diag), NewText: diag.Fix}
+		actions = append(actions, lspCodeAction{
+			Title:       diag.FixTitle,
+			Kind:        "quickfix",
+			Diagnostics: []lspDiagnostic{newLspDiagnostic(src, diag)},
+			Edit:        lspWorkspaceEdit{Changes: map[string][]lspTextEdit{uri: {edit}}},
+		})
+	}
+
+	sel := lspRangeSelect(src, r)
+	rule := *fill
+	if rule == "" {
+		rule = lspDefaultFill
+	}
+	candidates := []struct {
+		title string
+		setup func()
+	}{
+		{"Fill tags with " + rule, func() { *fill = rule }},
+		{"Sort tags", func() { *tagSort = true }},
+	}
+	base, err := s.format(uri, sel, nil)
+	if err != nil {
+		return actions, nil
+	}
+	for _, c := range candidates {
+		edits, err := s.format(uri, sel, c.setup)
+		// only offer the action that changes more than range formatting
+		if err != nil || len(edits) == 0 || reflect.DeepEqual(edits, base) {
+			continue
+		}
+		actions = append(actions, lspCodeAction{
+			Title: c.title,
+			Kind:  "refactor.rewrite",
+			Edit:  lspWorkspaceEdit{Changes: map[string][]lspTextEdit{uri: edits}},
+		})
+	}
+	return actions, nil
+}
+
+func (s *lspServer) publishDiagnostics(uri string) error {
+	src := s.docs[uri]
+	result := []lspDiagnostic{}
+	// the broken config is reported by formatting
+	if restore, err := applyConfig(lspFilename(uri)); err == nil {
+		defer restore()
+	}
+	diagnostics, err := lintSource(lspFilename(uri), src)
+	// syntax error is reported by other tools, only report tag problem
+	if err == nil {
+		for _, diag := range diagnostics {
+			result = append(result, newLspDiagnostic(src, diag))
+		}
+	}
+	return s.write(lspNotification{
+		JSONRPC: "2.0",
+		Method:  "textDocument/publishDiagnostics",
+		Params:  map[string]interface{}{"uri": uri, "diagnostics": result},
+	})
+}
+
+func newLspDiagnostic(src []byte, diag tagDiagnostic) lspDiagnostic {
+	return lspDiagnostic{
+		Range:    lspDiagnosticRange(src, diag),
+		Severity: lspSeverity(diag),
+		Source:   "tagfmt",
+		Message:  diag.Msg,
+	}
+}
+
+func lspDiagnosticRange(src []byte, diag tagDiagnostic) lspRange {
+	return lspRange{
+		Start: lspPositionOf(src, diag.Pos.Line, diag.Pos.Column),
+		End:   lspPositionOf(src, diag.End.Line, diag.End.Column),
+	}
+}
+
+// lspRangeSelect returns the struct select of the range, an empty range select the innermost struct
+func lspRangeSelect(src []byte, r lspRange) func(n *ast.StructType) bool {
+	if r.Start == r.End {
+		return positionSelect(cursorPosition{
+			Line:   r.Start.Line + 1,
+			Column: lspByteColumn(src, r.Start),
+		})
+	}
+	end := r.End.Line + 1
+	// range end at the beginning of line doesn't contain that line
+	if r.End.Character == 0 && r.End.Line > r.Start.Line {
+		end--
+	}
+	return lineRangeSelect([]lineRange{{r.Start.Line + 1, end}})
+}
+
+// lspSeverity returns 1 (error) for the error diagnostic, 2 (warning) for the others
+func lspSeverity(diag tagDiagnostic) int {
+	if diag.Error {
+		return 1
+	}
+	return 2
+}
+
+// lspFilename returns the file path of uri, the path of windows drive e.g /C:/src/a.go is C:\src\a.go
+func lspFilename(uri string) string {
+	if u, err := url.Parse(uri); err == nil && u.Scheme == "file" {
+		path := u.Path
+		if len(path) >= 3 && path[0] == '/' && path[2] == ':' &&
+			(path[1] >= 'a' && path[1] <= 'z' || path[1] >= 'A' && path[1] <= 'Z') {
+			path = path[1:]
+		}
+		return filepath.FromSlash(path)
+	}
+	return uri
+}
+
+func lspLine(src []byte, line int) []byte {
+	lines := bytes.Split(src, []byte{'\n'})
+	if line < 0 || line >= len(lines) {
+		return nil
+	}
+	return lines[line]
+}
+
+func utf16Len(b []byte) int {
+	n := 0
+	for len(b) > 0 {
+		r, size := utf8.DecodeRune(b)
+		n += len(utf16.Encode([]rune{r}))
+		b = b[size:]
+	}
+	return n
+}
+
+// lspPositionOf converts the 1-based line and byte column to lsp position
+func lspPositionOf(src []byte, line, column int) lspPosition {
+	text := lspLine(src, line-1)
+	if column-1 > len(text) {
+		column = len(text) + 1
+	}
+	return lspPosition{Line: line - 1, Character: utf16Len(text[:column-1])}
+}
+
+// lspByteColumn converts the lsp position to 1-based byte column
+func lspByteColumn(src []byte, p lspPosition) int {
+	text := lspLine(src, p.Line)
+	n, i := 0, 0
+	for i < len(text) && n < p.Character {
+		r, size := utf8.DecodeRune(text[i:])
+		n += len(utf16.Encode([]rune{r}))
+		i += size
+	}
+	return i + 1
+}
@@ -0,0 +1,159 @@
+/*
+ * Copyright 2020 bigpigeon. All rights reserved.
+ * Use of this source code is governed by a MIT style
+ * license that can be found in the LICENSE file.
+ *
+ */
+
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"path/filepath"
+	"testing"
+)
+
+func lspRequest(id int, method string, params interface{}) string {
+	data, err := json.Marshal(map[string]interface{}{"jsonrpc": "2.0", "id": id, "method": method, "params": params})
+	if err != nil {
+		panic(err)
+	}
+	return fmt.Sprintf("Content-Length: %d\r\n\r\n%s", len(data), data)
+}
+
+func lspNotify(method string, params interface{}) string {
+	data, err := json.Marshal(map[string]interface{}{"jsonrpc": "2.0", "method": method, "params": params})
+	if err != nil {
+		panic(err)
+	}
+	return fmt.Sprintf("Content-Length: %d\r\n\r\n%s", len(data), data)
+}
+
+func TestLspServer(t *testing.T) {
+	resetFlags()
+	initParserMode()
+	uri := "file:///tmp/api.go"
+	src := "package api\n\ntype User struct {\n\tID   string `json:\"id\" yaml:\"id\"`\n\tName string `json:\"name\" yaml:\"name\"`\n\tCity string `json:city`\n}\n"
+	doc := map[string]interface{}{"uri": uri}
+	in := lspRequest(1, "initialize", map[string]interface{}{}) +
+		lspNotify("textDocument/didOpen", map[string]interface{}{"textDocument": map[string]interface{}{"uri": uri, "text": src}}) +
+		lspRequest(2, "textDocument/codeAction", map[string]interface{}{"textDocument": doc,
+			"range": lspRange{Start: lspPosition{5, 1}, End: lspPosition{5, 1}}}) +
+		lspRequest(3, "shutdown", nil) +
+		lspNotify("exit", nil)
+	var out bytes.Buffer
+	require.NoError(t, newLspServer(bytes.NewBufferString(in), &out).serve())
+
+	messages := readLspFrames(t, out.Bytes())
+	require.Len(t, messages, 4)
+	assert.Equal(t, "textDocument/publishDiagnostics", messages[1]["method"])
+	diagnostics := messages[1]["params"].(map[string]interface{})["diagnostics"].([]interface{})
+	require.Len(t, diagnostics, 1)
+	assert.Equal(t, "malformed tag: Invalid tag", diagnostics[0].(map[string]interface{})["message"])
+	assert.Equal(t, float64(1), diagnostics[0].(map[string]interface{})["severity"])
+
+	actions := messages[2]["result"].([]interface{})
+	require.Len(t, actions, 1)
+	action := actions[0].(map[string]interface{})
+	assert.Equal(t, "Fix malformed tag", action["title"])
+	edit := action["edit"].(map[string]interface{})["changes"].(map[string]interface{})[uri].([]interface{})[0].(map[string]interface{})
+	assert.Equal(t, "`json:\"city\"`", edit["newText"])
+
+	// format the document after fix
+	fixed := "package api\n\ntype User struct {\n\tID   string `json:\"id\" yaml:\"id\"`\n\tName string `yaml:\"name\" json:\"name\"`\n\tCity string `json:\"city\"`\n}\n"
+	in = lspNotify("textDocument/didChange", map[string]interface{}{"textDocument": doc, "contentChanges": []interface{}{map[string]string{"text": fixed}}}) +
+		lspRequest(4, "textDocument/rangeFormatting", map[string]interface{}{"textDocument": doc,
+			"range": lspRange{Start: lspPosition{3, 0}, End: lspPosition{4, 0}}}) +
+		lspRequest(5, "textDocument/codeAction", map[string]interface{}{"textDocument": doc,
+			"range": lspRange{Start: lspPosition{3, 1}, End: lspPosition{3, 1}}})
+	server := newLspServer(bytes.NewBufferString(in), &out)
+	server.docs[uri] = []byte(src)
+	out.Reset()
+	require.NoError(t, server.serve())
+	messages = readLspFrames(t, out.Bytes())
+	require.Len(t, messages, 3)
+	assert.Empty(t, messages[0]["params"].(map[string]interface{})["diagnostics"])
+	edits := messages[1]["result"].([]interface{})
+	require.Len(t, edits, 1)
+	data, err := json.Marshal(edits[0])
+	require.NoError(t, err)
+	assert.JSONEq(t, `{"range":{"start":{"line":3,"character":24},"end":{"line":3,"character":24}},"newText":"  "}`, string(data))
+	var titles []string
+	for _, action := range messages[2]["result"].([]interface{}) {
+		titles = append(titles, action.(map[string]interface{})["title"].(string))
+	}
+	assert.Equal(t, []string{"Sort tags"}, titles)
+}
+
+func TestLspIncompleteTag(t *testing.T) {
+	resetFlags()
+	initParserMode()
+	uri := "file:///tmp/api.go"
+	src := "package api\n\ntype User struct {\n\tName string `json:`\n}\n"
+	in := lspNotify("textDocument/didOpen", map[string]interface{}{"textDocument": map[string]interface{}{"uri": uri, "text": src}}) +
+		lspRequest(1, "textDocument/formatting", map[string]interface{}{"textDocument": map[string]interface{}{"uri": uri}}) +
+		lspNotify("exit", nil)
+	var out bytes.Buffer
+	require.NoError(t, newLspServer(bytes.NewBufferString(in), &out).serve())
+	messages := readLspFrames(t, out.Bytes())
+	require.Len(t, messages, 2)
+	diagnostics := messages[0]["params"].(map[string]interface{})["diagnostics"].([]interface{})
+	require.Len(t, diagnostics, 1)
+	assert.Equal(t, "malformed tag: Invalid tag", diagnostics[0].(map[string]interface{})["message"])
+	assert.NotNil(t, messages[1]["error"])
+}
+
+func TestLspRecover(t *testing.T) {
+	// the server without documents panics when a document is opened
+	in := lspRequest(1, "textDocument/didOpen", map[string]interface{}{"textDocument": map[string]interface{}{"uri": "file:///tmp/a.go", "text": ""}}) +
+		lspRequest(2, "shutdown", nil)
+	var out bytes.Buffer
+	server := &lspServer{in: bufio.NewReader(bytes.NewBufferString(in)), out: &out}
+	require.NoError(t, server.serve())
+	messages := readLspFrames(t, out.Bytes())
+	require.Len(t, messages, 2)
+	assert.Contains(t, messages[0]["error"].(map[string]interface{})["message"], "internal error in textDocument/didOpen")
+	assert.Equal(t, float64(2), messages[1]["id"])
+}
+
+func TestLspFilename(t *testing.T) {
+	assert.Equal(t, filepath.FromSlash("/tmp/api.go"), lspFilename("file:///tmp/api.go"))
+	assert.Equal(t, filepath.FromSlash("C:/src/api.go"), lspFilename("file:///C:/src/api.go"))
+	assert.Equal(t, filepath.FromSlash("c:/my src/api.go"), lspFilename("file:///c%3A/my%20src/api.go"))
+	assert.Equal(t, "untitled:1", lspFilename("untitled:1"))
+}
+
+func TestLspSeverity(t *testing.T) {
+	resetFlags()
+	initParserMode()
+	src := "package api\n\ntype User struct {\n\tID   string `json:\"id,omitempty,omitemty\"`\n\tName string `json:name`\n}\n"
+	diagnostics, err := lintSource("api.go", []byte(src))
+	require.NoError(t, err)
+	var severities []int
+	for _, diag := range diagnostics {
+		severities = append(severities, newLspDiagnostic([]byte(src), diag).Severity)
+	}
+	assert.Equal(t, []int{2, 1}, severities)
+}
+
+func readLspFrames(t *testing.T, data []byte) []map[string]interface{} {
+	var messages []map[string]interface{}
+	for len(data) != 0 {
+		sep := bytes.Index(data, []byte("\r\n\r\n"))
+		require.NotEqual(t, -1, sep)
+		var length int
+		_, err := fmt.Sscanf(string(data[:sep]), "Content-Length: %d", &length)
+		require.NoError(t, err)
+		data = data[sep+4:]
+		var v map[string]interface{}
+		require.NoError(t, json.Unmarshal(data[:length], &v))
+		messages = append(messages, v)
+		data = data[length:]
+	}
+	return messages
+}
@@ -0,0 +1,393 @@
+/*
+ * Copyright 2020 bigpigeon. All rights reserved.
+ * Use of this source code is governed by a MIT style
+ * license that can be found in the LICENSE file.
+ *
+ */
+
+package main
+
+import (
+	"errors"
+	"go/ast"
+	"go/token"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// policyKey is a key in policy, Value is the required value if HasValue
+type policyKey struct {
+	Key      string
+	Value    string
+	HasValue bool
+}
+
+func (k policyKey) String() string {
+	if k.HasValue {
+		return k.Key + ":" + strconv.Quote(k.Value)
+	}
+	return k.Key
+}
+
+// tagPolicy is the rule of fields selected by conditions e.g
+// field=(?i)password|secret|token => require json=-
+// path=pkg/api exported => require json validate
+// path=pkg/api => forbid gorm
+type tagPolicy struct {
+	Source string
+	// the directory of config, path is relative to it
+	base       string
+	path       string
+	field      *regexp.Regexp
+	structName *regexp.Regexp
+	exported   bool
+	forbid     bool
+	keys       []policyKey
+}
+
+// activePolicies is the policies in the config of current file
+var activePolicies []*tagPolicy
+
+// parsePolicy parses the policy '<conditions> => require|forbid <keys>', conditions are separated by space
+// field=<regexp> struct=<regexp> path=<dir> exported, the value contains space is quoted e.g field="pass word",
+// the keys are separated by space, key=value requires the value
+func parsePolicy(source, base, s string) (*tagPolicy, error) {
+	condAction := strings.SplitN(s, "=>", 2)
+	if len(condAction) != 2 {
+		return nil, errors.New("invalid policy, expect '<conditions> => require|forbid <keys>'")
+	}
+	p := &tagPolicy{Source: source, base: base}
+	conds, err := splitPolicyConditions(condAction[0])
+	if err != nil {
+		return nil, err
+	}
+	for _, cond := range conds {
+		nameValue := strings.SplitN(cond, "=", 2)
+		if len(nameValue) == 2 && len(nameValue[1]) >= 2 && (nameValue[1][0] == '"' || nameValue[1][0] == '\'') {
+			if nameValue[1], err = unquotePolicyValue(nameValue[1]); err != nil {
+				return nil, errors.New("invalid policy condition " + cond + ": " + err.Error())
+			}
+		}
+		switch {
+		case cond == "exported":
+			p.exported = true
+		case len(nameValue) == 2 && nameValue[0] == "field":
+			p.field, err = regexp.Compile(nameValue[1])
+		case len(nameValue) == 2 && nameValue[0] == "struct":
+			p.structName, err = regexp.Compile(nameValue[1])
+		case len(nameValue) == 2 && nameValue[0] == "path":
+			p.path = filepath.ToSlash(filepath.Clean(nameValue[1]))
+		default:
+			return nil, errors.New("invalid policy condition " + cond)
+		}
+		if err != nil {
+			return nil, err
+		}
+	}
+	action := strings.Fields(condAction[1])
+	if len(action) < 2 || (action[0] != "require" && action[0] != "forbid") {
+		return nil, errors.New("invalid policy action, expect 'require|forbid <keys>'")
+	}
+	p.forbid = action[0] == "forbid"
+	for _, item := range action[1:] {
+		keyValue := strings.SplitN(item, "=", 2)
+		key := policyKey{Key: keyValue[0]}
+		if len(keyValue) == 2 {
+			if p.forbid {
+				return nil, errors.New("forbid policy doesn't accept value " + item)
+			}
+			key.Value, key.HasValue = keyValue[1], true
+		}
+		p.keys = append(p.keys, key)
+	}
+	return p, nil
+}
+
+// splitPolicyConditions splits the conditions by space, the space in quoted value is kept
+func splitPolicyConditions(s string) ([]string, error) {
+	var conds []string
+	var quote byte
+	start := -1
+	for i := 0; i < len(s); i++ {
+		c := s[i]
+		switch {
+		case quote != 0:
+			if c == '\\' && quote == '"' {
+				i++
+			} else if c == quote {
+				quote = 0
+			}
+		case c == ' ' || c == '\t':
+			if start != -1 {
+				conds = append(conds, s[start:i])
+				start = -1
+			}
+			continue
+		case c == '"' || c == '\'':
+			quote = c
+		}
+		if start == -1 {
+			start = i
+		}
+	}
+	if quote != 0 {
+		return nil, errors.New("invalid policy condition " + s[start:] + ": unclosed quote")
+	}
+	if start != -1 {
+		conds = append(conds, s[start:])
+	}
+	return conds, nil
+}
+
+// unquotePolicyValue unquotes the double quoted value by go syntax, the single quoted value is kept as it is
+func unquotePolicyValue(s string) (string, error) {
+	if s[0] == '\'' {
+		if s[len(s)-1] != '\'' {
+			return "", errors.New("unclosed quote")
+		}
+		return s[1 : len(s)-1], nil
+	}
+	return strconv.Unquote(s)
+}
+
+// matchFile reports whether the file is under the path of policy
+func (p *tagPolicy) matchFile(filename string) bool {
+	if p.path == "" || p.path == "." {
+		return true
+	}
+	abs, err := filepath.Abs(filename)
+	if err != nil {
+		return false
+	}
+	rel, err := filepath.Rel(p.base, filepath.Dir(abs))
+	if err != nil {
+		return false
+	}
+	rel = filepath.ToSlash(rel)
+	return rel == p.path || strings.HasPrefix(rel, p.path+"/")
+}
+
+// matchField returns the names of field match the policy, the embedded field has the empty name
+func (p *tagPolicy) matchField(structName string, field *ast.Field) []string {
+	if p.structName != nil && !p.structName.MatchString(structName) {
+		return nil
+	}
+	names := []string{""}
+	if len(field.Names) != 0 {
+		names = nil
+		for _, ident := range field.Names {
+			names = append(names, ident.Name)
+		}
+	}
+	var matched []string
+	for _, name := range names {
+		if p.exported && !ast.IsExported(name) {
+			continue
+		}
+		if p.field != nil && !p.field.MatchString(name) {
+			continue
+		}
+		matched = append(matched, name)
+	}
+	return matched
+}
+
+// policyViolation is a field breaks the policy, fix changes the key values to obey it, nil if it can't be fixed
+type policyViolation struct {
+	msg      string
+	fixTitle string
+	fix      func(keyValues []KeyValue) []KeyValue
+}
+
+// check returns the violations of field, name is the matched names of field, keyValues is the key values of field tag
+func (p *tagPolicy) check(name string, keyValues []KeyValue) []policyViolation {
+	var violations []policyViolation
+	for _, key := range p.keys {
+		index := -1
+		for i, kv := range keyValues {
+			if kv.Key == key.Key {
+				index = i
+				break
+			}
+		}
+		key := key
+		switch {
+		case p.forbid && index != -1:
+			violations = append(violations, policyViolation{
+				msg:      "policy: key " + key.Key + " is forbidden (" + p.Source + ")",
+				fixTitle: "Remove key " + key.Key,
+				fix: func(keyValues []KeyValue) []KeyValue {
+					var result []KeyValue
+					for _, kv := range keyValues {
+						if kv.Key != key.Key {
+							result = append(result, kv)
+						}
+					}
+					return result
+				},
+			})
+		case !p.forbid && index == -1 && !key.HasValue:
+			violations = append(violations, policyViolation{
+				msg: "policy: field " + name + " must have key " + key.Key + " (" + p.Source + ")",
+			})
+		case !p.forbid && key.HasValue && (index == -1 || keyValues[index].Value != key.Value):
+			violations = append(violations, policyViolation{
+				msg:      "policy: field " + name + " must have " + key.String() + " (" + p.Source + ")",
+				fixTitle: "Set " + key.String(),
+				fix: func(keyValues []KeyValue) []KeyValue {
+					result := append([]KeyValue{}, keyValues...)
+					for i, kv := range result {
+						if kv.Key == key.Key {
+							result[i].Value = key.Value
+							return result
+						}
+					}
+					return append(result, KeyValue{Key: key.Key, quote: "`", Value: key.Value})
+				},
+			})
+		}
+	}
+	return violations
+}
+
+// tagPolicyChecker reports the violations of policies and fixes them if fix is enabled
+type tagPolicyChecker struct {
+	f           *ast.File
+	fs          *token.FileSet
+	policies    []*tagPolicy
+	fix         bool
+	fields      []*ast.Field
+	violations  [][]policyViolation
+	diagnostics []tagDiagnostic
+}
+
+// Scan collects the violations and applies the fixes
+func (s *tagPolicyChecker) Scan() error {
+	if len(s.policies) == 0 {
+		return nil
+	}
+	ast.Walk(s, s.f)
+	if s.fix {
+		return s.apply(false)
+	}
+	return nil
+}
+
+// Execute checks the fields again and applies the fixes, the executors before it e.g fill may
+// change the values the policies require
+func (s *tagPolicyChecker) Execute() error {
+	if len(s.policies) == 0 || !s.fix {
+		return nil
+	}
+	s.fields, s.violations, s.diagnostics = nil, nil, nil
+	ast.Walk(s, s.f)
+	return s.apply(true)
+}
+
+// apply applies the fixes of violations, the tag is created or removed if need,
+// keepTag keeps the empty tag because the fields are collected by the following executors
+func (s *tagPolicyChecker) apply(keepTag bool) error {
+	for i, field := range s.fields {
+		quote, keyValues := "`", []KeyValue(nil)
+		if field.Tag != nil {
+			var err error
+			quote, keyValues, err = ParseTag(field.Tag.Value)
+			if err != nil {
+				return NewAstError(s.fs, field.Tag, err)
+			}
+		}
+		changed := false
+		for _, v := range s.violations[i] {
+			if v.fix != nil {
+				keyValues = v.fix(keyValues)
+				changed = true
+			}
+		}
+		if !changed {
+			continue
+		}
+		for i := range keyValues {
+			keyValues[i].quote = quote
+		}
+		if len(keyValues) == 0 && !keepTag {
+			field.Tag = nil
+			continue
+		}
+		if field.Tag == nil {
+			field.Tag = &ast.BasicLit{Kind: token.STRING, ValuePos: field.Type.End()}
+		}
+		field.Tag.Value = joinTag(quote, keyValues)
+		field.Tag.ValuePos = 0
+	}
+	return nil
+}
+
+func (s *tagPolicyChecker) Visit(node ast.Node) ast.Visitor {
+	cmap := ast.NewCommentMap(s.fs, node, s.f.Comments)
+	visit := newTopVisit(cmap, s.executor)
+	return visit.Visit(node)
+}
+
+func (s *tagPolicyChecker) executor(name string, comments []*ast.CommentGroup, n *ast.StructType) {
+	if n.Fields == nil {
+		return
+	}
+	for _, field := range n.Fields.List {
+		if !fieldFilter(getFieldOrTypeName(field)) {
+			continue
+		}
+		quote, keyValues := "`", []KeyValue(nil)
+		if field.Tag != nil {
+			var err error
+			quote, keyValues, err = ParseTag(field.Tag.Value)
+			if err != nil {
+				// reported by tag doctor
+				continue
+			}
+		}
+		var violations []policyViolation
+		for _, p := range s.policies {
+			if names := p.matchField(name, field); len(names) != 0 {
+				violations = append(violations, p.check(strings.Join(names, ", "), keyValues)...)
+			}
+		}
+		if len(violations) == 0 {
+			continue
+		}
+		s.fields = append(s.fields, field)
+		s.violations = append(s.violations, violations)
+		for _, v := range violations {
+			var diag tagDiagnostic
+			if field.Tag != nil {
+				diag = newTagDiagnostic(s.fs, field.Tag, v.msg)
+				if v.fix != nil {
+					fixed := v.fix(append([]KeyValue{}, keyValues...))
+					// the tag can't be removed by replacing the literal
+					if len(fixed) != 0 {
+						for i := range fixed {
+							fixed[i].quote = quote
+						}
+						diag.Fix, diag.FixTitle = joinTag(quote, fixed), v.fixTitle
+					}
+				}
+			} else {
+				diag = newTagDiagnostic(s.fs, field, v.msg)
+			}
+			diag.Error = true
+			s.diagnostics = append(s.diagnostics, diag)
+		}
+	}
+}
+
+// newTagPolicyCheck returns the checker of policies match the file
+func newTagPolicyCheck(f *ast.File, fs *token.FileSet, filename string, policies []*tagPolicy, fix bool) *tagPolicyChecker {
+	var matched []*tagPolicy
+	for _, p := range policies {
+		if p.matchFile(filename) {
+			matched = append(matched, p)
+		}
+	}
+	return &tagPolicyChecker{f: f, fs: fs, policies: matched, fix: fix}
+}
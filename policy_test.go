@@ -0,0 +1,152 @@
+/*
+ * Copyright 2020 bigpigeon. All rights reserved.
+ * Use of this source code is governed by a MIT style
+ * license that can be found in the LICENSE file.
+ *
+ */
+
+package main
+
+import (
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go/ast"
+	"go/parser"
+	"go/token"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestParsePolicy(t *testing.T) {
+	p, err := parsePolicy(".tagfmt:1", "/src", "field=(?i)password|secret struct=^User$ path=./pkg/api/ exported => require json=- validate")
+	require.NoError(t, err)
+	assert.Equal(t, "pkg/api", p.path)
+	assert.True(t, p.exported)
+	assert.Equal(t, []policyKey{{Key: "json", Value: "-", HasValue: true}, {Key: "validate"}}, p.keys)
+	p, err = parsePolicy(".tagfmt:1", "/src", `field="pass word|x\\.y"  struct='^My User$' => require json=-`)
+	require.NoError(t, err)
+	assert.Equal(t, `pass word|x\.y`, p.field.String())
+	assert.Equal(t, "^My User$", p.structName.String())
+	for _, s := range []string{
+		"field=\"pass word => require json",
+		"field=(?i)password require json=-",
+		"field=( => require json",
+		"fields=a => require json",
+		"=> remove json",
+		"=> require",
+		"=> forbid json=-",
+	} {
+		_, err := parsePolicy(".tagfmt:1", "/src", s)
+		assert.Error(t, err, s)
+	}
+}
+
+func TestPolicy(t *testing.T) {
+	resetFlags()
+	initParserMode()
+	dir, err := ioutil.TempDir("", "tagfmt_policy")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+	configCache = map[string]*tagConfig{}
+	defer func() { configCache = map[string]*tagConfig{} }()
+	require.NoError(t, ioutil.WriteFile(filepath.Join(dir, configName), []byte(
+		"policy = field=(?i)password|token => require json=-\n"+
+			"policy = path=api exported => require json validate\n"+
+			"policy = path=api => forbid gorm\n"), 0644))
+	src := "package api\n\ntype User struct {\n" +
+		"\tID       int    `json:\"id\" validate:\"required\" gorm:\"primaryKey\"`\n" +
+		"\tPassword string `json:\"password\" validate:\"required\"`\n" +
+		"\tToken    string\n" +
+		"\tName     string `gorm:\"size:10\"`\n" +
+		"\tname     string\n}\n"
+	config := filepath.Join(mustRel(t, dir), configName)
+	for filename, expected := range map[string][]string{
+		filepath.Join(dir, "api", "user.go"): {
+			"4:18: policy: key gorm is forbidden (" + config + ":3)",
+			"5:18: policy: field Password must have json:\"-\" (" + config + ":1)",
+			"6:2: policy: field Token must have json:\"-\" (" + config + ":1)",
+			"6:2: policy: field Token must have key json (" + config + ":2)",
+			"6:2: policy: field Token must have key validate (" + config + ":2)",
+			"7:18: policy: field Name must have key json (" + config + ":2)",
+			"7:18: policy: field Name must have key validate (" + config + ":2)",
+			"7:18: policy: key gorm is forbidden (" + config + ":3)",
+		},
+		filepath.Join(dir, "model", "user.go"): {
+			"5:18: policy: field Password must have json:\"-\" (" + config + ":1)",
+			"6:2: policy: field Token must have json:\"-\" (" + config + ":1)",
+		},
+	} {
+		restore, err := applyConfig(filename)
+		require.NoError(t, err)
+		diagnostics, err := lintSource(filename, []byte(src))
+		restore()
+		require.NoError(t, err)
+		var messages []string
+		for _, diag := range diagnostics {
+			messages = append(messages, strings.TrimPrefix(diag.String(), filename+":"))
+		}
+		assert.Equal(t, expected, messages, filename)
+	}
+	assert.Equal(t, "`json:\"-\" validate:\"required\"`", func() string {
+		restore, err := applyConfig(filepath.Join(dir, "api", "user.go"))
+		require.NoError(t, err)
+		defer restore()
+		diagnostics, err := lintSource(filepath.Join(dir, "api", "user.go"), []byte(src))
+		require.NoError(t, err)
+		return diagnostics[1].Fix
+	}())
+
+	*fix = true
+	filename := filepath.Join(dir, "api", "user.go")
+	restore, err := applyConfig(filename)
+	require.NoError(t, err)
+	defer restore()
+	res, err := formatSource(filename, []byte(src))
+	require.NoError(t, err)
+	assert.Equal(t, "package api\n\ntype User struct {\n"+
+		"\tID       int    `json:\"id\" validate:\"required\"`\n"+
+		"\tPassword string `json:\"-\"  validate:\"required\"`\n"+
+		"\tToken    string `json:\"-\"`\n"+
+		"\tName     string\n"+
+		"\tname     string\n}\n", string(res))
+}
+
+func TestPolicyMultipleNames(t *testing.T) {
+	p, err := parsePolicy(".tagfmt:1", "/src", "field=Secret => require json=-")
+	require.NoError(t, err)
+	file, err := parser.ParseFile(token.NewFileSet(), "a.go", "package a\ntype A struct {\n\tName, Secret string `json:\"name\"`\n}\n", 0)
+	require.NoError(t, err)
+	field := file.Decls[0].(*ast.GenDecl).Specs[0].(*ast.TypeSpec).Type.(*ast.StructType).Fields.List[0]
+	names := p.matchField("A", field)
+	assert.Equal(t, []string{"Secret"}, names)
+	violations := p.check(strings.Join(names, ", "), []KeyValue{{Key: "json", Value: "name", quote: "`"}})
+	require.Len(t, violations, 1)
+	assert.Equal(t, "policy: field Secret must have json:\"-\" (.tagfmt:1)", violations[0].msg)
+}
+
+func TestPolicyFixAfterFill(t *testing.T) {
+	resetFlags()
+	initParserMode()
+	defer resetFlags()
+	policy, err := parsePolicy(".tagfmt:1", ".", "field=Password => require json=-")
+	require.NoError(t, err)
+	activePolicies = []*tagPolicy{policy}
+	defer func() { activePolicies = nil }()
+	*fix = true
+	*fill = "json=snake(:field)"
+	src := "package main\n\ntype User struct {\n\tUserName string `json:\"\"`\n\tPassword string `json:\"password\"`\n}\n"
+	res, err := formatSource("user.go", []byte(src))
+	require.NoError(t, err)
+	assert.Equal(t, "package main\n\ntype User struct {\n\tUserName string `json:\"user_name\"`\n\tPassword string `json:\"-\"`\n}\n", string(res))
+}
+
+func mustRel(t *testing.T, dir string) string {
+	wd, err := os.Getwd()
+	require.NoError(t, err)
+	rel, err := filepath.Rel(wd, dir)
+	require.NoError(t, err)
+	return rel
+}
@@ -0,0 +1,352 @@
+/*
+ * Copyright 2020 bigpigeon. All rights reserved.
+ * Use of this source code is governed by a MIT style
+ * license that can be found in the LICENSE file.
+ *
+ */
+
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"go/ast"
+	"go/parser"
+	"go/token"
+	"io"
+	"io/ioutil"
+	"os"
+	"regexp"
+	"strings"
+)
+
+// tagPredicate reports whether the field of struct scope matches, tags is the key values of field tag
+type tagPredicate func(scope *structScope, field *ast.Field, tags map[string]string) bool
+
+// predicateParser parses the query predicate e.g has(json) && !has(yaml) && option(json, "omitempty")
+type predicateParser struct {
+	s   string
+	pos int
+}
+
+// parseTagPredicate parses the predicate, the operators are &&, ||, ! and brackets, the functions are
+// has(key), option(key, "opt"), eq(key, rule) and match(key, "regexp"), rule is same as the fill rule
+func parseTagPredicate(s string) (tagPredicate, error) {
+	p := &predicateParser{s: s}
+	pred, err := p.parseOr()
+	if err != nil {
+		return nil, err
+	}
+	p.skipSpace()
+	if p.pos != len(p.s) {
+		return nil, p.errorf("unexpected %q", p.s[p.pos:])
+	}
+	return pred, nil
+}
+
+func (p *predicateParser) errorf(format string, args ...interface{}) error {
+	return fmt.Errorf("invalid predicate at %d: "+format, append([]interface{}{p.pos}, args...)...)
+}
+
+func (p *predicateParser) skipSpace() {
+	for p.pos < len(p.s) && (p.s[p.pos] == ' ' || p.s[p.pos] == '\t') {
+		p.pos++
+	}
+}
+
+func (p *predicateParser) consume(op string) bool {
+	p.skipSpace()
+	if strings.HasPrefix(p.s[p.pos:], op) {
+		p.pos += len(op)
+		return true
+	}
+	return false
+}
+
+func (p *predicateParser) parseOr() (tagPredicate, error) {
+	left, err := p.parseAnd()
+	if err != nil {
+		return nil, err
+	}
+	for p.consume("||") {
+		right, err := p.parseAnd()
+		if err != nil {
+			return nil, err
+		}
+		l := left
+		left = func(scope *structScope, field *ast.Field, tags map[string]string) bool {
+			return l(scope, field, tags) || right(scope, field, tags)
+		}
+	}
+	return left, nil
+}
+
+func (p *predicateParser) parseAnd() (tagPredicate, error) {
+	left, err := p.parseUnary()
+	if err != nil {
+		return nil, err
+	}
+	for p.consume("&&") {
+		right, err := p.parseUnary()
+		if err != nil {
+			return nil, err
+		}
+		l := left
+		left = func(scope *structScope, field *ast.Field, tags map[string]string) bool {
+			return l(scope, field, tags) && right(scope, field, tags)
+		}
+	}
+	return left, nil
+}
+
+func (p *predicateParser) parseUnary() (tagPredicate, error) {
+	if p.consume("!") {
+		pred, err := p.parseUnary()
+		if err != nil {
+			return nil, err
+		}
+		return func(scope *structScope, field *ast.Field, tags map[string]string) bool {
+			return !pred(scope, field, tags)
+		}, nil
+	}
+	if p.consume("(") {
+		pred, err := p.parseOr()
+		if err != nil {
+			return nil, err
+		}
+		if !p.consume(")") {
+			return nil, p.errorf("missing ')'")
+		}
+		return pred, nil
+	}
+	return p.parseCall()
+}
+
+func (p *predicateParser) parseCall() (tagPredicate, error) {
+	p.skipSpace()
+	start := p.pos
+	for p.pos < len(p.s) && (p.s[p.pos] == '_' || isLetterOrDigit(p.s[p.pos])) {
+		p.pos++
+	}
+	name := p.s[start:p.pos]
+	if name == "" || !p.consume("(") {
+		return nil, p.errorf("expect function call")
+	}
+	end := findRightBracket(p.s[p.pos:])
+	if end == -1 {
+		return nil, ErrUnclosedBracket
+	}
+	argsStr := p.s[p.pos : p.pos+end]
+	p.pos += end + 1
+	key, arg := strings.TrimSpace(argsStr), ""
+	hasArg := false
+	if comma := strings.Index(argsStr, ","); comma != -1 {
+		key, arg, hasArg = strings.TrimSpace(argsStr[:comma]), strings.TrimSpace(argsStr[comma+1:]), true
+	}
+	if key == "" {
+		return nil, p.errorf("%s requires the tag key", name)
+	}
+	if hasArg != (name != "has") {
+		return nil, p.errorf("args number wrong of %s", name)
+	}
+	switch name {
+	case "has":
+		return func(scope *structScope, field *ast.Field, tags map[string]string) bool {
+			_, ok := tags[key]
+			return ok
+		}, nil
+	case "option":
+		opt := unquoteArg(arg)
+		return func(scope *structScope, field *ast.Field, tags map[string]string) bool {
+			value, ok := tags[key]
+			if !ok {
+				return false
+			}
+			v, _, err := ParseTagValue(key, value)
+			if v == nil && err == nil {
+				v, err = commaNamedGrammar.Parse(value)
+			}
+			if err != nil {
+				return false
+			}
+			_, ok = v.Option(opt)
+			return ok
+		}, nil
+	case "eq":
+		rule, err := parseFieldRulePlus(arg)
+		if err != nil {
+			return nil, err
+		}
+		return func(scope *structScope, field *ast.Field, tags map[string]string) bool {
+			value, ok := tags[key]
+			return ok && value == rule(newRuleArgs(scope, field, key, value))
+		}, nil
+	case "match":
+		re, err := regexp.Compile(unquoteArg(arg))
+		if err != nil {
+			return nil, err
+		}
+		return func(scope *structScope, field *ast.Field, tags map[string]string) bool {
+			value, ok := tags[key]
+			return ok && re.MatchString(value)
+		}, nil
+	}
+	return nil, errors.New("invalid predicate function " + name)
+}
+
+func isLetterOrDigit(c byte) bool {
+	return c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z' || c >= '0' && c <= '9'
+}
+
+func unquoteArg(s string) string {
+	if len(s) >= 2 && (s[0] == '"' || s[0] == '\'') && s[len(s)-1] == s[0] {
+		return s[1 : len(s)-1]
+	}
+	return s
+}
+
+// queryMatch is a field matched the query
+type queryMatch struct {
+	Pos    string `json:"pos"`
+	Struct string `json:"struct"`
+	Field  string `json:"field"`
+	Tag    string `json:"tag"`
+}
+
+func (m queryMatch) String() string {
+	return m.Pos + ": " + m.Struct + "." + m.Field + " " + m.Tag
+}
+
+// tagQuery is the executor that collects the fields match the predicate
+type tagQuery struct {
+	f         *ast.File
+	fs        *token.FileSet
+	predicate tagPredicate
+	matches   []queryMatch
+}
+
+func (s *tagQuery) Scan() error {
+	ast.Walk(s, s.f)
+	return nil
+}
+
+func (s *tagQuery) Execute() error {
+	return nil
+}
+
+func (s *tagQuery) Visit(node ast.Node) ast.Visitor {
+	cmap := ast.NewCommentMap(s.fs, node, s.f.Comments)
+	visit := newScopeVisit(cmap, s.executor)
+	return visit.Visit(node)
+}
+
+func (s *tagQuery) executor(scope *structScope, comments []*ast.CommentGroup, n *ast.StructType) {
+	if n.Fields == nil {
+		return
+	}
+	for _, field := range n.Fields.List {
+		if !fieldFilter(getFieldOrTypeName(field)) {
+			continue
+		}
+		tags := map[string]string{}
+		tag := ""
+		if field.Tag != nil {
+			_, keyValues, err := ParseTag(field.Tag.Value)
+			if err != nil {
+				continue
+			}
+			for _, kv := range keyValues {
+				tags[kv.Key] = kv.Value
+			}
+			tag = field.Tag.Value
+		}
+		if s.predicate(scope, field, tags) {
+			s.matches = append(s.matches, queryMatch{
+				Pos:    s.fs.Position(field.Pos()).String(),
+				Struct: scope.Name,
+				Field:  getFieldOrTypeName(field),
+				Tag:    tag,
+			})
+		}
+	}
+}
+
+func queryFile(fs *token.FileSet, filename string, src []byte, predicate tagPredicate) ([]queryMatch, error) {
+	file, err := parser.ParseFile(fs, filename, src, parserMode)
+	if err != nil {
+		return nil, err
+	}
+	q := &tagQuery{f: file, fs: fs, predicate: predicate}
+	if err := q.Scan(); err != nil {
+		return nil, err
+	}
+	return q.matches, nil
+}
+
+func writeQueryMatches(out io.Writer, format string, matches []queryMatch) error {
+	if format == "json" {
+		if matches == nil {
+			matches = []queryMatch{}
+		}
+		data, err := json.MarshalIndent(matches, "", "  ")
+		if err != nil {
+			return err
+		}
+		_, err = fmt.Fprintf(out, "%s\n", data)
+		return err
+	}
+	for _, m := range matches {
+		if _, err := fmt.Fprintln(out, m.String()); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func queryMain(args []string) error {
+	flags := flag.NewFlagSet("query", flag.ContinueOnError)
+	format := flags.String("format", "text", "output format text|json")
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+	if *format != "text" && *format != "json" {
+		return errors.New("unsupported format " + *format + " please check 'format' arg")
+	}
+	if flags.NArg() == 0 {
+		return errors.New("query requires the predicate")
+	}
+	predicate, err := parseTagPredicate(flags.Arg(0))
+	if err != nil {
+		return err
+	}
+	if err := selectInitFromFlags(); err != nil {
+		return err
+	}
+	paths := flags.Args()[1:]
+	if len(paths) == 0 {
+		paths = []string{"."}
+	}
+	filenames, err := goFiles(paths)
+	if err != nil {
+		return err
+	}
+	fs := token.NewFileSet()
+	var matches []queryMatch
+	for _, filename := range filenames {
+		src, err := ioutil.ReadFile(filename)
+		if err == nil {
+			var m []queryMatch
+			m, err = queryFile(fs, filename, src, predicate)
+			matches = append(matches, m...)
+		}
+		if err != nil {
+			report(err)
+		}
+	}
+	if len(matches) == 0 && exitCode == 0 {
+		exitCode = 1
+	}
+	return writeQueryMatches(os.Stdout, *format, matches)
+}
@@ -0,0 +1,74 @@
+/*
+ * Copyright 2020 bigpigeon. All rights reserved.
+ * Use of this source code is governed by a MIT style
+ * license that can be found in the LICENSE file.
+ *
+ */
+
+package main
+
+import (
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go/token"
+	"testing"
+)
+
+func TestQueryFile(t *testing.T) {
+	resetFlags()
+	initParserMode()
+	require.NoError(t, selectInitFromFlags())
+	src := "package main\n\ntype User struct {\n" +
+		"\tID       int    `json:\"id\" yaml:\"id\"`\n" +
+		"\tUserName string `json:\"user_name,omitempty\"`\n" +
+		"\tNickName string `json:\"nickName, omitempty\"`\n" +
+		"\tAge      int\n}\n"
+	for predicate, expected := range map[string][]string{
+		`has(json) && !has(yaml) && option(json, "omitempty")`: {"UserName", "NickName"},
+		`eq(json, snake(:field)+:tag_extra)`:                   {"ID", "UserName"},
+		`!has(json) || match(json, '^[a-z]+[A-Z]')`:            {"NickName", "Age"},
+		`(has(yaml) || has(xml)) && eq(yaml, 'id')`:            {"ID"},
+	} {
+		pred, err := parseTagPredicate(predicate)
+		require.NoError(t, err, predicate)
+		matches, err := queryFile(token.NewFileSet(), "query.go", []byte(src), pred)
+		require.NoError(t, err)
+		var fields []string
+		for _, m := range matches {
+			fields = append(fields, m.Field)
+		}
+		assert.Equal(t, expected, fields, predicate)
+	}
+	for _, predicate := range []string{`has(json`, `has(json) &&`, `has()`, `option(json)`, `unknown(json, 1)`, `has(json) x`} {
+		_, err := parseTagPredicate(predicate)
+		assert.Error(t, err, predicate)
+	}
+}
+
+func TestQueryFileScope(t *testing.T) {
+	resetFlags()
+	initParserMode()
+	require.NoError(t, selectInitFromFlags())
+	src := "package model\n\ntype User struct {\n" +
+		"\tID      int `table:\"user.id\"`\n" +
+		"\tName    int `table:\"model.name\"`\n" +
+		"\tAddress struct {\n" +
+		"\t\tCity string `table:\"address.city\"`\n" +
+		"\t\tZip  string `table:\"user.zip\"`\n" +
+		"\t}\n}\n"
+	for predicate, expected := range map[string][]string{
+		`eq(table, snake(:struct)+'.'+snake(:field))`: {"ID", "Zip"},
+		`eq(table, snake(:parent)+'.'+snake(:field))`: {"City"},
+		`eq(table, :pkg+'.'+snake(:field))`:           {"Name"},
+	} {
+		pred, err := parseTagPredicate(predicate)
+		require.NoError(t, err, predicate)
+		matches, err := queryFile(token.NewFileSet(), "query.go", []byte(src), pred)
+		require.NoError(t, err)
+		var fields []string
+		for _, m := range matches {
+			fields = append(fields, m.Field)
+		}
+		assert.Equal(t, expected, fields, predicate)
+	}
+}
@@ -0,0 +1,398 @@
+/*
+ * Copyright 2020 bigpigeon. All rights reserved.
+ * Use of this source code is governed by a MIT style
+ * license that can be found in the LICENSE file.
+ *
+ */
+
+package main
+
+import (
+	"fmt"
+	"io/ioutil"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// ddlColumn is the column definition in CREATE TABLE or ALTER TABLE ADD COLUMN
+type ddlColumn struct {
+	Name       string
+	Type       string
+	NotNull    bool
+	PrimaryKey bool
+}
+
+// ddlTable is the table after applying all statements, Pos is where the table is created
+type ddlTable struct {
+	Name    string
+	Pos     string
+	Columns []*ddlColumn
+}
+
+// column returns the column with name case insensitively, nil if not found
+func (t *ddlTable) column(name string) *ddlColumn {
+	for _, c := range t.Columns {
+		if strings.EqualFold(c.Name, name) {
+			return c
+		}
+	}
+	return nil
+}
+
+func (t *ddlTable) dropColumn(name string) {
+	for i, c := range t.Columns {
+		if strings.EqualFold(c.Name, name) {
+			t.Columns = append(t.Columns[:i], t.Columns[i+1:]...)
+			return
+		}
+	}
+}
+
+// ddlSchema is the tables of DDL files, the key is lower case table name
+type ddlSchema map[string]*ddlTable
+
+// sqlToken is the token of sql, quoted is true for quoted identifier and string
+type sqlToken struct {
+	text   string
+	quoted bool
+	line   int
+}
+
+// keyword reports whether the token is the unquoted keyword case insensitively
+func (t sqlToken) keyword(k string) bool {
+	return !t.quoted && strings.EqualFold(t.text, k)
+}
+
+// tokenizeSQL splits the sql to tokens, comments are skipped, the rest of file is ignored
+// after the down migration marker e.g '-- +goose Down'
+func tokenizeSQL(src string) ([]sqlToken, error) {
+	var tokens []sqlToken
+	line := 1
+	for i := 0; i < len(src); i++ {
+		c := src[i]
+		switch {
+		case c == '\n':
+			line++
+		case c == ' ' || c == '\t' || c == '\r':
+		case c == '-' && i+1 < len(src) && src[i+1] == '-':
+			end := strings.IndexByte(src[i:], '\n')
+			if end == -1 {
+				end = len(src) - i
+			}
+			comment := strings.TrimSpace(src[i+2 : i+end])
+			if strings.HasPrefix(comment, "+goose Down") || strings.HasPrefix(comment, "+migrate Down") {
+				return tokens, nil
+			}
+			i += end - 1
+		case c == '/' && i+1 < len(src) && src[i+1] == '*':
+			end := strings.Index(src[i+2:], "*/")
+			if end == -1 {
+				return nil, fmt.Errorf("line %d: unclosed comment", line)
+			}
+			line += strings.Count(src[i:i+2+end], "\n")
+			i += end + 3
+		case c == '\'' || c == '"' || c == '`' || c == '[':
+			closing := c
+			if c == '[' {
+				closing = ']'
+			}
+			j := i + 1
+			for ; j < len(src); j++ {
+				if src[j] == closing {
+					// the doubled quote is escaped quote
+					if j+1 < len(src) && src[j+1] == closing && closing != ']' {
+						j++
+						continue
+					}
+					break
+				}
+			}
+			if j >= len(src) {
+				return nil, fmt.Errorf("line %d: unclosed quote", line)
+			}
+			text := src[i+1 : j]
+			if c == '\'' {
+				// string literal keeps the quote e.g enum('a','b')
+				text = src[i : j+1]
+			}
+			tokens = append(tokens, sqlToken{text: text, quoted: true, line: line})
+			line += strings.Count(src[i:j], "\n")
+			i = j
+		case strings.IndexByte("(),;.", c) != -1:
+			tokens = append(tokens, sqlToken{text: string(c), line: line})
+		default:
+			j := i
+			for j < len(src) && strings.IndexByte(" \t\r\n(),;.'\"`[", src[j]) == -1 {
+				j++
+			}
+			if j == i {
+				j++
+			}
+			tokens = append(tokens, sqlToken{text: src[i:j], line: line})
+			i = j - 1
+		}
+	}
+	return tokens, nil
+}
+
+// splitSQLTokens splits tokens with the unquoted sep outside brackets
+func splitSQLTokens(tokens []sqlToken, sep string) [][]sqlToken {
+	var parts [][]sqlToken
+	depth, pre := 0, 0
+	for i, t := range tokens {
+		switch {
+		case t.keyword("("):
+			depth++
+		case t.keyword(")"):
+			depth--
+		case t.keyword(sep) && depth == 0:
+			parts = append(parts, tokens[pre:i])
+			pre = i + 1
+		}
+	}
+	if pre < len(tokens) {
+		parts = append(parts, tokens[pre:])
+	}
+	return parts
+}
+
+// sqlTypeText joins the tokens of column type e.g varchar ( 64 ) => varchar(64)
+func sqlTypeText(tokens []sqlToken) string {
+	var b strings.Builder
+	for i, t := range tokens {
+		text := t.text
+		if i != 0 && !t.keyword("(") && !t.keyword(")") && !t.keyword(",") &&
+			!tokens[i-1].keyword("(") && !tokens[i-1].keyword(",") {
+			b.WriteByte(' ')
+		}
+		b.WriteString(text)
+	}
+	return b.String()
+}
+
+// columnConstraints are the keywords end the type of column definition
+var columnConstraints = map[string]bool{
+	"NOT": true, "NULL": true, "DEFAULT": true, "PRIMARY": true, "UNIQUE": true, "REFERENCES": true,
+	"CHECK": true, "CONSTRAINT": true, "AUTO_INCREMENT": true, "AUTOINCREMENT": true, "COLLATE": true,
+	"GENERATED": true, "COMMENT": true, "ON": true, "CHARACTER": true, "CHARSET": true, "IDENTITY": true,
+}
+
+// tableConstraints are the first keywords of table constraint in CREATE TABLE
+var tableConstraints = map[string]bool{
+	"CONSTRAINT": true, "PRIMARY": true, "UNIQUE": true, "KEY": true, "INDEX": true, "FOREIGN": true,
+	"CHECK": true, "FULLTEXT": true, "SPATIAL": true, "EXCLUDE": true,
+}
+
+// parseColumn parses the column definition e.g name varchar(64) NOT NULL
+func parseColumn(tokens []sqlToken) *ddlColumn {
+	if len(tokens) == 0 {
+		return nil
+	}
+	c := &ddlColumn{Name: tokens[0].text}
+	i := 1
+	for ; i < len(tokens); i++ {
+		t := tokens[i]
+		if i > 1 && !t.quoted && columnConstraints[strings.ToUpper(t.text)] {
+			break
+		}
+		if t.keyword("(") {
+			// skip the args of type
+			for depth := 0; i < len(tokens); i++ {
+				if tokens[i].keyword("(") {
+					depth++
+				} else if tokens[i].keyword(")") {
+					depth--
+					if depth == 0 {
+						break
+					}
+				}
+			}
+		}
+	}
+	if i > len(tokens) {
+		i = len(tokens)
+	}
+	c.Type = sqlTypeText(tokens[1:i])
+	for j := i; j < len(tokens); j++ {
+		switch {
+		case tokens[j].keyword("NOT") && j+1 < len(tokens) && tokens[j+1].keyword("NULL"):
+			c.NotNull = true
+		case tokens[j].keyword("PRIMARY") && j+1 < len(tokens) && tokens[j+1].keyword("KEY"):
+			c.PrimaryKey = true
+		}
+	}
+	return c
+}
+
+// parseTableName parses the table name e.g public.users, returns the name and the rest tokens
+func parseTableName(tokens []sqlToken) (string, []sqlToken) {
+	if len(tokens) == 0 {
+		return "", nil
+	}
+	name := tokens[0].text
+	tokens = tokens[1:]
+	for len(tokens) >= 2 && tokens[0].keyword(".") {
+		name = tokens[1].text
+		tokens = tokens[2:]
+	}
+	return name, tokens
+}
+
+// skipKeywords skips the leading keywords e.g IF NOT EXISTS
+func skipKeywords(tokens []sqlToken, keywords ...string) []sqlToken {
+	for _, k := range keywords {
+		if len(tokens) == 0 || !tokens[0].keyword(k) {
+			return tokens
+		}
+		tokens = tokens[1:]
+	}
+	return tokens
+}
+
+// applyStatement applies the CREATE TABLE, ALTER TABLE and DROP TABLE statement to schema,
+// the other statements are ignored
+func (s ddlSchema) applyStatement(filename string, tokens []sqlToken) {
+	if len(tokens) < 3 {
+		return
+	}
+	pos := fmt.Sprintf("%s:%d", filename, tokens[0].line)
+	switch {
+	case tokens[0].keyword("CREATE"):
+		rest := skipKeywords(tokens[1:], "TEMPORARY")
+		rest = skipKeywords(rest, "TEMP")
+		if len(rest) == 0 || !rest[0].keyword("TABLE") {
+			return
+		}
+		rest = skipKeywords(rest[1:], "IF", "NOT", "EXISTS")
+		name, rest := parseTableName(rest)
+		if len(rest) == 0 || !rest[0].keyword("(") {
+			return
+		}
+		table := &ddlTable{Name: name, Pos: pos}
+		var body []sqlToken
+		for i, depth := 0, 0; i < len(rest); i++ {
+			if rest[i].keyword("(") {
+				depth++
+			} else if rest[i].keyword(")") {
+				depth--
+				if depth == 0 {
+					body = rest[1:i]
+					break
+				}
+			}
+		}
+		var primaryKeys []string
+		for _, item := range splitSQLTokens(body, ",") {
+			if len(item) == 0 {
+				continue
+			}
+			if !item[0].quoted && tableConstraints[strings.ToUpper(item[0].text)] {
+				primaryKeys = append(primaryKeys, tablePrimaryKeys(item)...)
+				continue
+			}
+			table.Columns = append(table.Columns, parseColumn(item))
+		}
+		for _, key := range primaryKeys {
+			if c := table.column(key); c != nil {
+				c.PrimaryKey = true
+			}
+		}
+		s[strings.ToLower(name)] = table
+	case tokens[0].keyword("ALTER") && tokens[1].keyword("TABLE"):
+		rest := skipKeywords(tokens[2:], "IF", "EXISTS")
+		rest = skipKeywords(rest, "ONLY")
+		name, rest := parseTableName(rest)
+		table := s[strings.ToLower(name)]
+		if table == nil {
+			return
+		}
+		for _, action := range splitSQLTokens(rest, ",") {
+			switch {
+			case len(action) > 1 && action[0].keyword("ADD"):
+				action = skipKeywords(action[1:], "COLUMN")
+				action = skipKeywords(action, "IF", "NOT", "EXISTS")
+				if len(action) != 0 && (action[0].quoted || !tableConstraints[strings.ToUpper(action[0].text)]) {
+					table.Columns = append(table.Columns, parseColumn(action))
+				} else {
+					for _, key := range tablePrimaryKeys(action) {
+						if c := table.column(key); c != nil {
+							c.PrimaryKey = true
+						}
+					}
+				}
+			case len(action) > 1 && action[0].keyword("DROP"):
+				action = skipKeywords(action[1:], "COLUMN")
+				action = skipKeywords(action, "IF", "EXISTS")
+				if len(action) != 0 {
+					table.dropColumn(action[0].text)
+				}
+			case len(action) == 5 && action[0].keyword("RENAME") && action[1].keyword("COLUMN") && action[3].keyword("TO"):
+				if c := table.column(action[2].text); c != nil {
+					c.Name = action[4].text
+				}
+			}
+		}
+	case tokens[0].keyword("DROP") && tokens[1].keyword("TABLE"):
+		rest := skipKeywords(tokens[2:], "IF", "EXISTS")
+		for _, item := range splitSQLTokens(rest, ",") {
+			name, _ := parseTableName(item)
+			delete(s, strings.ToLower(name))
+		}
+	}
+}
+
+// tablePrimaryKeys returns the columns of PRIMARY KEY (a, b) in table constraint
+func tablePrimaryKeys(tokens []sqlToken) []string {
+	for i := 0; i+2 < len(tokens); i++ {
+		if tokens[i].keyword("PRIMARY") && tokens[i+1].keyword("KEY") && tokens[i+2].keyword("(") {
+			var keys []string
+			for _, t := range tokens[i+3:] {
+				if t.keyword(")") {
+					break
+				}
+				if !t.keyword(",") {
+					keys = append(keys, t.text)
+				}
+			}
+			return keys
+		}
+	}
+	return nil
+}
+
+// parseDDL applies the statements of sql source to schema
+func (s ddlSchema) parseDDL(filename, src string) error {
+	tokens, err := tokenizeSQL(src)
+	if err != nil {
+		return fmt.Errorf("%s: %s", filename, err)
+	}
+	for _, stmt := range splitSQLTokens(tokens, ";") {
+		s.applyStatement(filename, stmt)
+	}
+	return nil
+}
+
+// loadDDLSchema reads the .sql files in dir in the order of file name, the down migrations
+// e.g 001_init.down.sql are skipped
+func loadDDLSchema(dir string) (ddlSchema, error) {
+	matches, err := filepath.Glob(filepath.Join(dir, "*.sql"))
+	if err != nil {
+		return nil, err
+	}
+	sort.Strings(matches)
+	schema := ddlSchema{}
+	for _, filename := range matches {
+		if strings.HasSuffix(filename, ".down.sql") {
+			continue
+		}
+		src, err := ioutil.ReadFile(filename)
+		if err != nil {
+			return nil, err
+		}
+		if err := schema.parseDDL(filename, string(src)); err != nil {
+			return nil, err
+		}
+	}
+	return schema, nil
+}
@@ -0,0 +1,46 @@
+/*
+ * Copyright 2020 bigpigeon. All rights reserved.
+ * Use of this source code is governed by a MIT style
+ * license that can be found in the LICENSE file.
+ *
+ */
+
+package main
+
+import (
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"testing"
+)
+
+func TestParseDDL(t *testing.T) {
+	schema := ddlSchema{}
+	src := `-- +goose Up
+CREATE TABLE IF NOT EXISTS public.users (
+    id BIGINT NOT NULL AUTO_INCREMENT,
+    name VARCHAR(64) NOT NULL DEFAULT 'a;b',
+    "price" decimal(10, 2),
+    legacy text, /* dropped later */
+    CONSTRAINT users_pk PRIMARY KEY (id)
+);
+ALTER TABLE users ADD COLUMN created_at timestamp with time zone NOT NULL, DROP COLUMN legacy;
+ALTER TABLE users RENAME COLUMN price TO amount;
+CREATE INDEX users_name ON users (name);
+-- +goose Down
+DROP TABLE users;
+`
+	require.NoError(t, schema.parseDDL("001.sql", src))
+	table := schema["users"]
+	require.NotNil(t, table)
+	assert.Equal(t, "001.sql:2", table.Pos)
+	assert.Equal(t, []*ddlColumn{
+		{Name: "id", Type: "BIGINT", NotNull: true, PrimaryKey: true},
+		{Name: "name", Type: "VARCHAR(64)", NotNull: true},
+		{Name: "amount", Type: "decimal(10,2)"},
+		{Name: "created_at", Type: "timestamp with time zone", NotNull: true},
+	}, table.Columns)
+
+	require.NoError(t, schema.parseDDL("002.sql", "DROP TABLE IF EXISTS users;"))
+	assert.Nil(t, schema["users"])
+	assert.Error(t, schema.parseDDL("003.sql", "CREATE TABLE a (name varchar(64) DEFAULT 'x);"))
+}
@@ -0,0 +1,385 @@
+/*
+ * Copyright 2020 bigpigeon. All rights reserved.
+ * Use of this source code is governed by a MIT style
+ * license that can be found in the LICENSE file.
+ *
+ */
+
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"go/ast"
+	"go/parser"
+	"go/token"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+	"unicode"
+)
+
+// the naming conventions of tag names, lower and upper are single word that compatible with others
+const (
+	conventionSnake          = "snake"
+	conventionScreamingSnake = "screaming_snake"
+	conventionKebab          = "kebab"
+	conventionLowerCamel     = "lower_camel"
+	conventionUpperCamel     = "upper_camel"
+	conventionLower          = "lower"
+	conventionUpper          = "upper"
+	conventionOther          = "other"
+)
+
+// conventionPriority decides the majority when the votes are equal
+var conventionPriority = []string{conventionLower, conventionUpper, conventionSnake, conventionLowerCamel,
+	conventionUpperCamel, conventionKebab, conventionScreamingSnake}
+
+// conventionCompatible is the conventions that a single word can be considered as
+var conventionCompatible = map[string][]string{
+	conventionLower: {conventionSnake, conventionKebab, conventionLowerCamel},
+	conventionUpper: {conventionScreamingSnake, conventionUpperCamel},
+}
+
+// namingConvention returns the naming convention of name
+func namingConvention(name string) string {
+	hasLower, hasUpper, hasUnderscore, hasDash := false, false, false, false
+	for _, r := range name {
+		switch {
+		case unicode.IsLower(r):
+			hasLower = true
+		case unicode.IsUpper(r):
+			hasUpper = true
+		case r == '_':
+			hasUnderscore = true
+		case r == '-':
+			hasDash = true
+		case unicode.IsDigit(r):
+		default:
+			return conventionOther
+		}
+	}
+	if name == "" || !unicode.IsLetter([]rune(name)[0]) || (hasUnderscore && hasDash) {
+		return conventionOther
+	}
+	switch {
+	case hasUnderscore && !hasUpper:
+		return conventionSnake
+	case hasUnderscore && !hasLower:
+		return conventionScreamingSnake
+	case hasDash && !hasUpper:
+		return conventionKebab
+	case hasUnderscore || hasDash:
+		return conventionOther
+	case hasLower && hasUpper && unicode.IsLower([]rune(name)[0]):
+		return conventionLowerCamel
+	case hasLower && hasUpper:
+		return conventionUpperCamel
+	case hasLower:
+		return conventionLower
+	}
+	return conventionUpper
+}
+
+// tagValueName returns the name in tag value that follows a naming convention, false if the value has no name
+func tagValueName(key, value string) (string, bool) {
+	v, g, err := ParseTagValue(key, value)
+	if err != nil {
+		return "", false
+	}
+	if v == nil {
+		// the value without grammar is a name if it's a single word
+		if value == "" || value == "-" || strings.ContainsAny(value, " ,:;=") {
+			return "", false
+		}
+		return value, true
+	}
+	if lg, ok := g.(listGrammar); ok && lg.named {
+		return v.Name, v.Name != "" && v.Name != "-"
+	}
+	if opt, ok := v.Option("column"); ok && opt.Value != "" {
+		return opt.Value, true
+	}
+	return "", false
+}
+
+// tagName is a name used in tag value
+type tagName struct {
+	Pos        token.Position
+	Name       string
+	Convention string
+}
+
+// tagKeyStats is the usage of a tag key
+type tagKeyStats struct {
+	Key         string         `json:"key"`
+	Fields      int            `json:"fields"`
+	Structs     int            `json:"structs"`
+	Packages    int            `json:"packages"`
+	Conventions map[string]int `json:"conventions"`
+	Majority    string         `json:"majority"`
+	Outliers    []tagOutlier   `json:"outliers"`
+
+	structs  map[string]bool
+	packages map[string]bool
+	names    []tagName
+}
+
+type tagOutlier struct {
+	Pos        string `json:"pos"`
+	Name       string `json:"name"`
+	Convention string `json:"convention"`
+}
+
+// tagStats collects the tag usage of files
+type tagStats struct {
+	keys map[string]*tagKeyStats
+}
+
+func newTagStats() *tagStats {
+	return &tagStats{keys: map[string]*tagKeyStats{}}
+}
+
+// statsCollector is the executor that only scans the file
+type statsCollector struct {
+	f     *ast.File
+	fs    *token.FileSet
+	pkg   string
+	stats *tagStats
+}
+
+func (s *statsCollector) Scan() error {
+	ast.Walk(s, s.f)
+	return nil
+}
+
+func (s *statsCollector) Execute() error {
+	return nil
+}
+
+func (s *statsCollector) Visit(node ast.Node) ast.Visitor {
+	cmap := ast.NewCommentMap(s.fs, node, s.f.Comments)
+	visit := newTopVisit(cmap, s.executor)
+	return visit.Visit(node)
+}
+
+func (s *statsCollector) executor(name string, comments []*ast.CommentGroup, n *ast.StructType) {
+	if n.Fields == nil {
+		return
+	}
+	structID := s.pkg + "." + name + "@" + strconv.Itoa(int(n.Pos()))
+	for _, field := range n.Fields.List {
+		if field.Tag == nil || !fieldFilter(getFieldOrTypeName(field)) {
+			continue
+		}
+		_, keyValues, err := ParseTag(field.Tag.Value)
+		if err != nil {
+			continue
+		}
+		for _, kv := range keyValues {
+			ks := s.stats.key(kv.Key)
+			ks.Fields++
+			ks.structs[structID] = true
+			ks.packages[s.pkg] = true
+			if tagName, ok := tagValueName(kv.Key, kv.Value); ok {
+				ks.add(s.fs.Position(field.Tag.Pos()), tagName)
+			}
+		}
+	}
+}
+
+func (s *tagStats) key(key string) *tagKeyStats {
+	ks := s.keys[key]
+	if ks == nil {
+		ks = &tagKeyStats{Key: key, Conventions: map[string]int{}, structs: map[string]bool{}, packages: map[string]bool{}}
+		s.keys[key] = ks
+	}
+	return ks
+}
+
+func (ks *tagKeyStats) add(pos token.Position, name string) {
+	convention := namingConvention(name)
+	ks.Conventions[convention]++
+	ks.names = append(ks.names, tagName{Pos: pos, Name: name, Convention: convention})
+}
+
+// finish computes the majority convention and the names deviate from it
+func (ks *tagKeyStats) finish() {
+	ks.Structs, ks.Packages = len(ks.structs), len(ks.packages)
+	// the single word is also counted as its compatible conventions to decide the majority
+	votes := map[string]int{}
+	for convention, count := range ks.Conventions {
+		votes[convention] += count
+		for _, c := range conventionCompatible[convention] {
+			votes[c] += count
+		}
+	}
+	best := 0
+	for _, convention := range conventionPriority {
+		if votes[convention] > best {
+			ks.Majority, best = convention, votes[convention]
+		}
+	}
+	if ks.Majority == "" {
+		return
+	}
+	for _, n := range ks.names {
+		if n.Convention == ks.Majority || conventionMatch(n.Convention, ks.Majority) {
+			continue
+		}
+		ks.Outliers = append(ks.Outliers, tagOutlier{Pos: n.Pos.String(), Name: n.Name, Convention: n.Convention})
+	}
+}
+
+func conventionMatch(convention, majority string) bool {
+	for _, c := range conventionCompatible[convention] {
+		if c == majority {
+			return true
+		}
+	}
+	return false
+}
+
+func sortedConventions(m map[string]int) []string {
+	var result []string
+	for c := range m {
+		result = append(result, c)
+	}
+	sort.Strings(result)
+	return result
+}
+
+// result returns the stats of all keys sorted by field count
+func (s *tagStats) result() []*tagKeyStats {
+	var result []*tagKeyStats
+	for _, ks := range s.keys {
+		ks.finish()
+		result = append(result, ks)
+	}
+	sort.Slice(result, func(i, j int) bool {
+		if result[i].Fields != result[j].Fields {
+			return result[i].Fields > result[j].Fields
+		}
+		return result[i].Key < result[j].Key
+	})
+	return result
+}
+
+func (s *tagStats) addFile(fs *token.FileSet, filename string, src []byte) error {
+	file, err := parser.ParseFile(fs, filename, src, parserMode)
+	if err != nil {
+		return err
+	}
+	collector := &statsCollector{f: file, fs: fs, pkg: filepath.Dir(filename) + ":" + file.Name.Name, stats: s}
+	return collector.Scan()
+}
+
+func writeTagStats(out io.Writer, format string, result []*tagKeyStats) error {
+	if format == "json" {
+		if result == nil {
+			result = []*tagKeyStats{}
+		}
+		data, err := json.MarshalIndent(result, "", "  ")
+		if err != nil {
+			return err
+		}
+		_, err = fmt.Fprintf(out, "%s\n", data)
+		return err
+	}
+	w := tabwriter.NewWriter(out, 0, 8, 2, ' ', 0)
+	fmt.Fprintln(w, "key\tfields\tstructs\tpackages\tconventions")
+	for _, ks := range result {
+		total := 0
+		for _, count := range ks.Conventions {
+			total += count
+		}
+		conventions := sortedConventions(ks.Conventions)
+		sort.SliceStable(conventions, func(i, j int) bool {
+			return ks.Conventions[conventions[i]] > ks.Conventions[conventions[j]]
+		})
+		var cells []string
+		for _, c := range conventions {
+			cells = append(cells, fmt.Sprintf("%s %.1f%%", c, float64(ks.Conventions[c])*100/float64(total)))
+		}
+		fmt.Fprintf(w, "%s\t%d\t%d\t%d\t%s\n", ks.Key, ks.Fields, ks.Structs, ks.Packages, strings.Join(cells, ", "))
+	}
+	if err := w.Flush(); err != nil {
+		return err
+	}
+	for _, ks := range result {
+		for _, o := range ks.Outliers {
+			fmt.Fprintf(out, "%s: %s: %s is %s, the majority is %s\n", o.Pos, ks.Key, strconv.Quote(o.Name),
+				o.Convention, ks.Majority)
+		}
+	}
+	return nil
+}
+
+// goFiles returns the go files of paths except the test files, path end with /... means all sub directories
+func goFiles(paths []string) ([]string, error) {
+	var filenames []string
+	for _, p := range paths {
+		if info, err := os.Stat(p); err == nil && !info.IsDir() {
+			filenames = append(filenames, p)
+			continue
+		}
+		dirs, err := packageDirs([]string{p})
+		if err != nil {
+			return nil, err
+		}
+		for _, dir := range dirs {
+			infos, err := ioutil.ReadDir(dir)
+			if err != nil {
+				report(err)
+				continue
+			}
+			for _, info := range infos {
+				if isGoFile(info) && !strings.HasSuffix(info.Name(), "_test.go") {
+					filenames = append(filenames, filepath.Join(dir, info.Name()))
+				}
+			}
+		}
+	}
+	return filenames, nil
+}
+
+func statsMain(args []string) error {
+	flags := flag.NewFlagSet("stats", flag.ContinueOnError)
+	format := flags.String("format", "text", "output format text|json")
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+	if *format != "text" && *format != "json" {
+		return errors.New("unsupported format " + *format + " please check 'format' arg")
+	}
+	if err := selectInitFromFlags(); err != nil {
+		return err
+	}
+	paths := flags.Args()
+	if len(paths) == 0 {
+		paths = []string{"."}
+	}
+	filenames, err := goFiles(paths)
+	if err != nil {
+		return err
+	}
+	stats := newTagStats()
+	fs := token.NewFileSet()
+	for _, filename := range filenames {
+		src, err := ioutil.ReadFile(filename)
+		if err == nil {
+			err = stats.addFile(fs, filename, src)
+		}
+		if err != nil {
+			report(err)
+		}
+	}
+	return writeTagStats(os.Stdout, *format, stats.result())
+}
@@ -0,0 +1,73 @@
+/*
+ * Copyright 2020 bigpigeon. All rights reserved.
+ * Use of this source code is governed by a MIT style
+ * license that can be found in the LICENSE file.
+ *
+ */
+
+package main
+
+import (
+	"bytes"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go/token"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestNamingConvention(t *testing.T) {
+	for name, expected := range map[string]string{
+		"user_name": conventionSnake,
+		"USER_NAME": conventionScreamingSnake,
+		"user-name": conventionKebab,
+		"userName":  conventionLowerCamel,
+		"UserName":  conventionUpperCamel,
+		"name":      conventionLower,
+		"ID":        conventionUpper,
+		"User_name": conventionOther,
+		"1name":     conventionOther,
+		"user.name": conventionOther,
+	} {
+		assert.Equal(t, expected, namingConvention(name), name)
+	}
+}
+
+func TestTagStats(t *testing.T) {
+	resetFlags()
+	initParserMode()
+	require.NoError(t, selectInitFromFlags())
+	src := "package main\n\ntype User struct {\n" +
+		"\tID       int    `json:\"id\" gorm:\"column:id;primaryKey\" validate:\"required\"`\n" +
+		"\tUserName string `json:\"user_name\" gorm:\"column:user_name\"`\n" +
+		"\tNickName string `json:\"nickName,omitempty\"`\n" +
+		"\tAge      int    `json:\"age_year\"`\n" +
+		"\tSkip     string `json:\"-\"`\n}\n\ntype Order struct {\n\tID int `json:\"id\"`\n}\n"
+	stats := newTagStats()
+	require.NoError(t, stats.addFile(token.NewFileSet(), "stats.go", []byte(src)))
+	var out bytes.Buffer
+	require.NoError(t, writeTagStats(&out, "text", stats.result()))
+	assert.Equal(t, "key       fields  structs  packages  conventions\n"+
+		"json      6       2        1         lower 40.0%, snake 40.0%, lower_camel 20.0%\n"+
+		"gorm      2       1        1         lower 50.0%, snake 50.0%\n"+
+		"validate  1       1        1         \n"+
+		"stats.go:6:18: json: \"nickName\" is lower_camel, the majority is snake\n", out.String())
+}
+
+func TestGoFiles(t *testing.T) {
+	dir, err := ioutil.TempDir("", "tagfmt_files")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+	require.NoError(t, os.MkdirAll(filepath.Join(dir, "sub"), 0755))
+	for _, name := range []string{"a.go", "a_test.go", "sub/b.go"} {
+		require.NoError(t, ioutil.WriteFile(filepath.Join(dir, name), []byte("package a\n"), 0644))
+	}
+	files, err := goFiles([]string{dir})
+	require.NoError(t, err)
+	assert.Equal(t, []string{filepath.Join(dir, "a.go")}, files)
+	files, err = goFiles([]string{dir + "/..."})
+	require.NoError(t, err)
+	assert.Equal(t, []string{filepath.Join(dir, "a.go"), filepath.Join(dir, "sub", "b.go")}, files)
+}
@@ -0,0 +1,224 @@
+/*
+ * Copyright 2020 bigpigeon. All rights reserved.
+ * Use of this source code is governed by a MIT style
+ * license that can be found in the LICENSE file.
+ *
+ */
+
+package main
+
+import (
+	"fmt"
+	"go/ast"
+	"go/token"
+	"io"
+	"os"
+	"strings"
+)
+
+// autoFillRule is the fill rule that infer the convention from existing tags
+const autoFillRule = "auto"
+
+// explainOut is where -explain writes the inferred conventions
+var explainOut io.Writer = os.Stderr
+
+// conventionConverts converts the field name to the naming convention
+var conventionConverts = map[string]func(name string) string{
+	conventionSnake:          snakeConvert,
+	conventionScreamingSnake: func(name string) string { return strings.ToUpper(snakeConvert(name)) },
+	conventionKebab:          func(name string) string { return strings.Replace(snakeConvert(name), "_", "-", -1) },
+	conventionLowerCamel:     func(name string) string { return lowerCamelConvert(upperCamelConvert(snakeConvert(name))) },
+	conventionUpperCamel:     func(name string) string { return name },
+	conventionLower:          strings.ToLower,
+	conventionUpper:          strings.ToUpper,
+}
+
+// keyConvention is the inferred convention of tag key
+type keyConvention struct {
+	Convention string
+	// the options most of the values have e.g ,omitempty
+	Extra   string
+	Samples int
+}
+
+// fillableTagValue returns the name and options of value, false if the key has no name to fill
+func fillableTagValue(key, value string) (name, extra string, ok bool) {
+	v, g, err := ParseTagValue(key, value)
+	if err != nil {
+		return "", "", false
+	}
+	if v == nil {
+		if strings.ContainsAny(value, " ,:;=") {
+			return "", "", false
+		}
+		return value, "", true
+	}
+	if lg, isList := g.(listGrammar); !isList || !lg.named {
+		return "", "", false
+	}
+	if comma := strings.Index(value, ","); comma != -1 {
+		return value[:comma], value[comma:], true
+	}
+	return value, "", true
+}
+
+// inferConventions returns the dominant naming convention and options of each key in the tags of fields
+func inferConventions(fields []*ast.Field) map[string]keyConvention {
+	stats := newTagStats()
+	extras := map[string]map[string]int{}
+	for _, field := range fields {
+		if field.Tag == nil {
+			continue
+		}
+		_, keyValues, err := ParseTag(field.Tag.Value)
+		if err != nil {
+			continue
+		}
+		for _, kv := range keyValues {
+			name, extra, ok := fillableTagValue(kv.Key, kv.Value)
+			if !ok || name == "" || name == "-" {
+				continue
+			}
+			stats.key(kv.Key).add(token.Position{}, name)
+			if extras[kv.Key] == nil {
+				extras[kv.Key] = map[string]int{}
+			}
+			extras[kv.Key][extra]++
+		}
+	}
+	result := map[string]keyConvention{}
+	for key, ks := range stats.keys {
+		ks.finish()
+		if ks.Majority == "" {
+			continue
+		}
+		kc := keyConvention{Convention: ks.Majority, Samples: len(ks.names)}
+		for extra, count := range extras[key] {
+			if count*2 > kc.Samples {
+				kc.Extra = extra
+			}
+		}
+		result[key] = kc
+	}
+	return result
+}
+
+// structFields returns the fields of all structs in files
+func structFields(files []*ast.File) []*ast.Field {
+	var fields []*ast.Field
+	for _, f := range files {
+		ast.Inspect(f, func(n ast.Node) bool {
+			if st, ok := n.(*ast.StructType); ok && st.Fields != nil {
+				fields = append(fields, st.Fields.List...)
+			}
+			return true
+		})
+	}
+	return fields
+}
+
+// autoFillRuleOf returns the fill rule of key, it only fills the empty name
+func autoFillRuleOf(key string, kc keyConvention) tagFieldRule {
+	convert := conventionConverts[kc.Convention]
+	return func(args *ruleFuncArgs) string {
+		name, extra, ok := fillableTagValue(key, args.OldTag)
+		if !ok || name != "" {
+			return args.OldTag
+		}
+		if args.OldTag == "" {
+			extra = kc.Extra
+		}
+		return convert(getFieldName(args.Field)) + extra
+	}
+}
+
+type autoFillFields struct {
+	fields  []*ast.Field
+	ruleSet map[string]tagFieldRule
+}
+
+// tagAutoFiller fills the missing keys and empty names of struct with the inferred convention,
+// the convention of struct is used first, then the convention of package
+type tagAutoFiller struct {
+	f        *ast.File
+	fs       *token.FileSet
+	filename string
+	explain  bool
+	pkg      map[string]keyConvention
+	needFill []autoFillFields
+}
+
+func (s *tagAutoFiller) Scan() error {
+	s.pkg = inferConventions(structFields(append(siblingFiles(s.filename, s.f), s.f)))
+	ast.Walk(s, s.f)
+	return nil
+}
+
+func (s *tagAutoFiller) Execute() error {
+	for _, needFill := range s.needFill {
+		fieldsTagFill(nil, needFill.fields, nil, needFill.ruleSet, nil)
+	}
+	return nil
+}
+
+func (s *tagAutoFiller) Visit(node ast.Node) ast.Visitor {
+	cmap := ast.NewCommentMap(s.fs, node, s.f.Comments)
+	visit := newTopVisit(cmap, s.executor)
+	return visit.Visit(node)
+}
+
+func (s *tagAutoFiller) executor(name string, comments []*ast.CommentGroup, n *ast.StructType) {
+	if n.Fields == nil {
+		return
+	}
+	var fields []*ast.Field
+	keySet := map[string]bool{}
+	for _, field := range n.Fields.List {
+		if field.Tag == nil || !fieldFilter(getFieldOrTypeName(field)) {
+			continue
+		}
+		_, keyValues, err := ParseTag(field.Tag.Value)
+		if err != nil {
+			// reported by tag doctor
+			return
+		}
+		fields = append(fields, field)
+		for _, kv := range keyValues {
+			keySet[kv.Key] = true
+		}
+	}
+	if len(fields) == 0 {
+		return
+	}
+	local := inferConventions(fields)
+	ruleSet := map[string]tagFieldRule{}
+	for _, key := range sortedKeys(keySet) {
+		kc, ok := local[key]
+		scope := "struct"
+		// the single words of struct can't tell the convention, e.g id is snake or lower camel
+		if pkgKc, pkgOk := s.pkg[key]; pkgOk && !ok {
+			kc, ok, scope = pkgKc, true, "package"
+		} else if pkgOk && conventionMatch(kc.Convention, pkgKc.Convention) {
+			kc.Convention, scope = pkgKc.Convention, "struct and package"
+		}
+		if !ok {
+			continue
+		}
+		ruleSet[key] = autoFillRuleOf(key, kc)
+		if s.explain {
+			extra := ""
+			if kc.Extra != "" {
+				extra = " with " + kc.Extra
+			}
+			fmt.Fprintf(explainOut, "%s: %s: %s is %s%s inferred from %d %s tags\n",
+				s.fs.Position(n.Pos()), name, key, kc.Convention, extra, kc.Samples, scope)
+		}
+	}
+	if len(ruleSet) != 0 {
+		s.needFill = append(s.needFill, autoFillFields{fields: fields, ruleSet: ruleSet})
+	}
+}
+
+func newTagAutoFill(f *ast.File, fs *token.FileSet, filename string, explain bool) *tagAutoFiller {
+	return &tagAutoFiller{f: f, fs: fs, filename: filename, explain: explain}
+}
@@ -0,0 +1,35 @@
+/*
+ * Copyright 2020 bigpigeon. All rights reserved.
+ * Use of this source code is governed by a MIT style
+ * license that can be found in the LICENSE file.
+ *
+ */
+
+package main
+
+import (
+	"bytes"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"os"
+	"testing"
+)
+
+func TestAutoFillExplain(t *testing.T) {
+	resetFlags()
+	initParserMode()
+	*fill = autoFillRule
+	*explain = true
+	var out bytes.Buffer
+	explainOut = &out
+	defer func() { explainOut = os.Stderr }()
+	src := "package main\n\ntype User struct {\n\tID       int    `json:\"id\" yaml:\"ID,omitempty\"`\n" +
+		"\tUserName string `json:\"user-name\" yaml:\"UserName,omitempty\"`\n\tAge      int    `json:\"\"`\n}\n"
+	res, err := formatSource("explain.go", []byte(src))
+	require.NoError(t, err)
+	assert.Equal(t, "package main\n\ntype User struct {\n\tID       int    `json:\"id\"        yaml:\"ID,omitempty\"`\n"+
+		"\tUserName string `json:\"user-name\" yaml:\"UserName,omitempty\"`\n\tAge      int    `json:\"age\"       yaml:\"Age,omitempty\"`\n}\n",
+		string(res))
+	assert.Equal(t, "explain.go:3:11: User: json is kebab inferred from 2 struct tags\n"+
+		"explain.go:3:11: User: yaml is upper_camel with ,omitempty inferred from 2 struct tags\n", out.String())
+}
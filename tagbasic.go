@@ -11,10 +11,121 @@ import "go/ast"
 
 type toyVisitExecutor func(name string, comments []*ast.CommentGroup, n *ast.StructType)
 
+// structScope is where the struct is declared, Name is empty for anonymous struct,
+// Field is the field of Parent whose type is the struct
+type structScope struct {
+	Name   string
+	Pkg    string
+	Struct *ast.StructType
+	Parent *structScope
+	Field  *ast.Field
+
+	// link returns the scope of named struct with the parent field uses it, nil if not found
+	link func(s *structScope) *structScope
+}
+
+// maxScopeDepth limits the nesting of scopes, the named structs may use each other
+const maxScopeDepth = 32
+
+// Up returns the parent scope and the field of parent whose type is this struct,
+// the named struct follows the field uses it if link is set
+func (s *structScope) Up() (*structScope, *ast.Field) {
+	if s == nil {
+		return nil, nil
+	}
+	if s.Parent != nil {
+		return s.Parent, s.Field
+	}
+	if s.link != nil {
+		if linked := s.link(s); linked != nil {
+			return linked.Parent, linked.Field
+		}
+	}
+	return nil, nil
+}
+
+// StructName returns the name of struct, the anonymous struct uses the name of nearest named parent
+func (s *structScope) StructName() string {
+	for ; s != nil; s = s.Parent {
+		if s.Name != "" {
+			return s.Name
+		}
+	}
+	return ""
+}
+
+// FieldPath returns the names of fields from the top struct to this struct
+func (s *structScope) FieldPath() []string {
+	var path []string
+	for parent, field := s.Up(); field != nil && len(path) < maxScopeDepth; parent, field = parent.Up() {
+		path = append([]string{getFieldOrTypeName(field)}, path...)
+	}
+	return path
+}
+
+// FieldIndex returns the index of field in struct, the field with multiple names counts each name,
+// -1 if not found
+func (s *structScope) FieldIndex(field *ast.Field) int {
+	if s == nil || s.Struct == nil || s.Struct.Fields == nil {
+		return -1
+	}
+	index := 0
+	for _, f := range s.Struct.Fields.List {
+		if f == field {
+			return index
+		}
+		if len(f.Names) == 0 {
+			index++
+		}
+		index += len(f.Names)
+	}
+	return -1
+}
+
+// inspectScopes calls fn with the scope of every struct in file, unlike toyVisit
+// it ignores the struct, field and range selection
+func inspectScopes(f *ast.File, fn func(scope *structScope, n *ast.StructType)) {
+	var rangeField func(scope *structScope, fields *ast.FieldList)
+	rangeField = func(scope *structScope, fields *ast.FieldList) {
+		if fields == nil {
+			return
+		}
+		for _, field := range fields.List {
+			if _struct, ok := field.Type.(*ast.StructType); ok {
+				child := &structScope{Pkg: scope.Pkg, Struct: _struct, Parent: scope, Field: field}
+				fn(child, _struct)
+				rangeField(child, _struct.Fields)
+			}
+		}
+	}
+	ast.Inspect(f, func(node ast.Node) bool {
+		switch n := node.(type) {
+		case *ast.TypeSpec:
+			if typ, ok := n.Type.(*ast.StructType); ok {
+				scope := &structScope{Name: n.Name.Name, Pkg: f.Name.Name, Struct: typ}
+				fn(scope, typ)
+				rangeField(scope, typ.Fields)
+			}
+			return false
+		case *ast.StructType:
+			scope := &structScope{Pkg: f.Name.Name, Struct: n}
+			fn(scope, n)
+			rangeField(scope, n.Fields)
+			return false
+		}
+		return true
+	})
+}
+
+// scopeVisitExecutor is the executor needs the scope of struct
+type scopeVisitExecutor func(scope *structScope, comments []*ast.CommentGroup, n *ast.StructType)
+
 type toyVisit struct {
-	executor toyVisitExecutor
-	cmap     ast.CommentMap
-	Comments []*ast.CommentGroup
+	executor      toyVisitExecutor
+	scopeExecutor scopeVisitExecutor
+	cmap          ast.CommentMap
+	pkg           string
+	Comments      []*ast.CommentGroup
 }
 
 func newTopVisit(cmap ast.CommentMap, executor toyVisitExecutor) *toyVisit {
@@ -24,30 +135,54 @@ func newTopVisit(cmap ast.CommentMap, executor toyVisitExecutor) *toyVisit {
 	}
 }
 
+// newScopeVisit returns the visitor calls executor with the scope of struct
+func newScopeVisit(cmap ast.CommentMap, executor scopeVisitExecutor) *toyVisit {
+	return &toyVisit{
+		cmap:          cmap,
+		scopeExecutor: executor,
+	}
+}
+
 func (s *toyVisit) Copy() *toyVisit {
 	comments := make([]*ast.CommentGroup, len(s.Comments))
 	copy(comments, s.Comments)
 	return &toyVisit{
-		executor: s.executor,
-		cmap:     s.cmap,
-		Comments: comments,
+		executor:      s.executor,
+		scopeExecutor: s.scopeExecutor,
+		cmap:          s.cmap,
+		pkg:           s.pkg,
+		Comments:      comments,
 	}
 }
 
 func (s *toyVisit) WithComments(comments []*ast.CommentGroup) *toyVisit {
 	return &toyVisit{
-		executor: s.executor,
-		cmap:     s.cmap,
-		Comments: comments,
+		executor:      s.executor,
+		scopeExecutor: s.scopeExecutor,
+		cmap:          s.cmap,
+		pkg:           s.pkg,
+		Comments:      comments,
+	}
+}
+
+// execute calls the executor if the struct is in the selected range
+func (s *toyVisit) execute(scope *structScope, n *ast.StructType) {
+	if structRangeSelect == nil || structRangeSelect(n) {
+		if s.scopeExecutor != nil {
+			s.scopeExecutor(scope, s.Comments, n)
+		} else {
+			s.executor(scope.Name, s.Comments, n)
+		}
 	}
 }
 
-func (s *toyVisit) rangeField(fields *ast.FieldList) {
+func (s *toyVisit) rangeField(scope *structScope, fields *ast.FieldList) {
 	if fields != nil {
 		for _, f := range fields.List {
 			if _struct, ok := f.Type.(*ast.StructType); ok {
-				s.executor("", s.Comments, _struct)
-				s.rangeField(_struct.Fields)
+				child := &structScope{Pkg: scope.Pkg, Struct: _struct, Parent: scope, Field: f}
+				s.execute(child, _struct)
+				s.rangeField(child, _struct.Fields)
 			}
 		}
 	}
@@ -55,6 +190,8 @@ func (s *toyVisit) rangeField(fields *ast.FieldList) {
 
 func (s *toyVisit) Visit(node ast.Node) ast.Visitor {
 	switch n := node.(type) {
+	case *ast.File:
+		s.pkg = n.Name.Name
 	case *ast.GenDecl:
 		if comments := s.cmap[n]; len(comments) != 0 {
 			return s.WithComments(comments)
@@ -67,15 +204,17 @@ func (s *toyVisit) Visit(node ast.Node) ast.Visitor {
 		name := n.Name.Name
 		if typ, ok := n.Type.(*ast.StructType); ok {
 			if structFieldSelect(name) {
-				s.executor(name, s.Comments, typ)
-				s.rangeField(typ.Fields)
+				scope := &structScope{Name: name, Pkg: s.pkg, Struct: typ}
+				s.execute(scope, typ)
+				s.rangeField(scope, typ.Fields)
 			}
 		}
 		return nil
 	case *ast.StructType:
 		if structFieldSelect("") {
-			s.executor("", s.Comments, n)
-			s.rangeField(n.Fields)
+			scope := &structScope{Pkg: s.pkg, Struct: n}
+			s.execute(scope, n)
+			s.rangeField(scope, n.Fields)
 		}
 		return nil
 	}
@@ -10,6 +10,7 @@ package main
 import (
 	"go/ast"
 	"go/token"
+	"strings"
 )
 
 const tagDockerMaxErr = 5
@@ -25,9 +26,10 @@ func (e tagDockerErr) Error() string {
 }
 
 type tagDoctor struct {
-	f   *ast.File
-	fs  *token.FileSet
-	Err tagDockerErr
+	f           *ast.File
+	fs          *token.FileSet
+	Err         tagDockerErr
+	diagnostics []tagDiagnostic
 }
 
 func (s *tagDoctor) Visit(node ast.Node) ast.Visitor {
@@ -49,6 +51,13 @@ func (t *tagDoctor) executor(name string, comments []*ast.CommentGroup, n *ast.S
 					if len(t.Err) < tagDockerMaxErr {
 						t.Err = append(t.Err, NewAstError(t.fs, field.Tag, err))
 					}
+					diag := newTagDiagnostic(t.fs, field.Tag, "malformed tag: "+strings.TrimSpace(err.Error()))
+					diag.Error = true
+					if fixed, ok := repairTag(field.Tag.Value); ok {
+						diag.Fix = fixed
+						diag.FixTitle = "Fix malformed tag"
+					}
+					t.diagnostics = append(t.diagnostics, diag)
 				}
 			}
 		}
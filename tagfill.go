@@ -9,26 +9,34 @@ package main
 
 import (
 	"errors"
+	"fmt"
 	"go/ast"
 	"go/token"
+	"path/filepath"
 	"sort"
+	"strconv"
 	"strings"
 )
 
 type tagFillerFields struct {
+	scope     *structScope
 	fields    []*ast.Field
 	keySet    map[string]struct{}
 	tagFilter map[string]bool
 }
 
 type ruleFuncArgs struct {
+	Scope  *structScope // the struct of field, nil if unknown
 	Field  *ast.Field
+	Key    string // the key is filling
 	OldTag string // old tag value
 }
 
-func newRuleArgs(f *ast.Field, oldTag string) *ruleFuncArgs {
+func newRuleArgs(scope *structScope, f *ast.Field, key, oldTag string) *ruleFuncArgs {
 	return &ruleFuncArgs{
+		Scope:  scope,
 		Field:  f,
+		Key:    key,
 		OldTag: oldTag,
 	}
 }
@@ -39,8 +47,18 @@ type tagFiller struct {
 	Err          error
 	f            *ast.File
 	fs           *token.FileSet
+	filename     string
 	ruleSet      map[string]tagFieldRule
+	ignored      ignoreModes
 	needFillList []tagFillerFields
+	// the fields use the named struct types of package, it's collected when the first named struct is linked
+	typeUses map[string][]typeUse
+}
+
+// typeUse is the field whose type is the named struct
+type typeUse struct {
+	scope *structScope
+	field *ast.Field
 }
 
 func ruleSetClone(rs map[string]tagFieldRule) map[string]tagFieldRule {
@@ -57,9 +75,10 @@ func (s *tagFiller) Scan() error {
 }
 
 func (s *tagFiller) Execute() error {
+	var flagged []ignoredKey
 	for _, needFill := range s.needFillList {
 		if needFill.tagFilter == nil {
-			fieldsTagFill(needFill.fields, needFill.keySet, s.ruleSet)
+			flagged = append(flagged, fieldsTagFill(needFill.scope, needFill.fields, needFill.keySet, s.ruleSet, s.ignored)...)
 		} else {
 			ruleSet := map[string]tagFieldRule{}
 			for key, rule := range s.ruleSet {
@@ -67,15 +86,19 @@ func (s *tagFiller) Execute() error {
 					ruleSet[key] = rule
 				}
 			}
-			fieldsTagFill(needFill.fields, needFill.keySet, ruleSet)
+			flagged = append(flagged, fieldsTagFill(needFill.scope, needFill.fields, needFill.keySet, ruleSet, s.ignored)...)
 		}
 	}
+	for _, ignored := range flagged {
+		msg := "field " + getFieldOrTypeName(ignored.Field) + " is unexported, " + ignored.Key + " has no effect"
+		fmt.Fprintln(fillIgnoredOut, newTagDiagnostic(s.fs, ignored.Field, msg).String())
+	}
 	return nil
 }
 
 func (s *tagFiller) Visit(node ast.Node) ast.Visitor {
 	cmap := ast.NewCommentMap(s.fs, node, s.f.Comments)
-	visit := newTopVisit(cmap, s.executor)
+	visit := newScopeVisit(cmap, s.executor)
 	return visit.Visit(node)
 }
 
@@ -102,7 +125,44 @@ func (s *tagFiller) findCommentTags(comments []*ast.CommentGroup) map[string]boo
 	return tags
 }
 
-func (s *tagFiller) executor(name string, comments []*ast.CommentGroup, n *ast.StructType) {
+// link returns the scope of named struct with the field uses it, nil if the struct is used by none
+// or more than one field in package
+func (s *tagFiller) link(scope *structScope) *structScope {
+	if s.typeUses == nil {
+		s.typeUses = map[string][]typeUse{}
+		// the uses are collected from all structs, the selected structs may be used by the others
+		collect := func(f *ast.File) {
+			inspectScopes(f, func(scope *structScope, n *ast.StructType) {
+				if scope.Parent == nil {
+					scope.link = s.link
+				}
+				for _, field := range n.Fields.List {
+					typ := field.Type
+					if star, ok := typ.(*ast.StarExpr); ok {
+						typ = star.X
+					}
+					if ident, ok := typ.(*ast.Ident); ok && len(field.Names) != 0 {
+						s.typeUses[ident.Name] = append(s.typeUses[ident.Name], typeUse{scope, field})
+					}
+				}
+			})
+		}
+		for _, f := range siblingFiles(s.filename, s.f) {
+			collect(f)
+		}
+		collect(s.f)
+	}
+	uses := s.typeUses[scope.Name]
+	if len(uses) != 1 {
+		return nil
+	}
+	return &structScope{Name: scope.Name, Pkg: scope.Pkg, Struct: scope.Struct, Parent: uses[0].scope, Field: uses[0].field}
+}
+
+func (s *tagFiller) executor(scope *structScope, comments []*ast.CommentGroup, n *ast.StructType) {
+	if scope.Parent == nil && scope.Name != "" {
+		scope.link = s.link
+	}
 	if n.Fields != nil {
 		keySet := map[string]struct{}{}
 		var cacheFieldList []*ast.Field
@@ -116,7 +176,7 @@ func (s *tagFiller) executor(name string, comments []*ast.CommentGroup, n *ast.S
 			line := s.fs.Position(field.Pos()).Line
 			// If there are blank lines or nil field tag in the structure, reset
 			if field.Tag == nil || preFieldLine+1 < line {
-				s.needFillList = append(s.needFillList, tagFillerFields{cacheFieldList, keySet, tagsFilter})
+				s.needFillList = append(s.needFillList, tagFillerFields{scope, cacheFieldList, keySet, tagsFilter})
 				keySet = map[string]struct{}{}
 				cacheFieldList = nil
 			}
@@ -134,12 +194,15 @@ func (s *tagFiller) executor(name string, comments []*ast.CommentGroup, n *ast.S
 			}
 		}
 		if cacheFieldList != nil {
-			s.needFillList = append(s.needFillList, tagFillerFields{cacheFieldList, keySet, tagsFilter})
+			s.needFillList = append(s.needFillList, tagFillerFields{scope, cacheFieldList, keySet, tagsFilter})
 		}
 	}
 }
 
-func fieldsTagFill(fields []*ast.Field, keySet map[string]struct{}, ruleSet map[string]tagFieldRule) {
+// fieldsTagFill fills the fields by rules, the fields ignored by encoder are treated as the mode of key,
+// it returns the keys of unexported fields should be flagged
+func fieldsTagFill(scope *structScope, fields []*ast.Field, keySet map[string]struct{}, ruleSet map[string]tagFieldRule, ignored ignoreModes) []ignoredKey {
+	var flagged []ignoredKey
 	for _, f := range fields {
 		if f.Tag != nil {
 			rs := ruleSetClone(ruleSet)
@@ -166,10 +229,13 @@ func fieldsTagFill(fields []*ast.Field, keySet map[string]struct{}, ruleSet map[
 				}
 
 				for _, k := range missingKeys {
+					if ignored.skipFill(f, k, "") {
+						continue
+					}
 					appendKeyValues = append(appendKeyValues, KeyValue{
 						Key:   k,
 						quote: quote,
-						Value: fillMissing(newRuleArgs(f, "")),
+						Value: fillMissing(newRuleArgs(scope, f, k, "")),
 					})
 				}
 
@@ -178,8 +244,11 @@ func fieldsTagFill(fields []*ast.Field, keySet map[string]struct{}, ruleSet map[
 			missingRuleSet := ruleSetClone(rs)
 
 			for i, kv := range keyValues {
-				if rs[kv.Key] != nil {
-					keyValues[i].Value = rs[kv.Key](newRuleArgs(f, kv.Value))
+				if ignored.Mode(kv.Key) == ignoreFlag && isUnexportedField(f) && !isIgnoreMarker(kv.Value) {
+					flagged = append(flagged, ignoredKey{Field: f, Key: kv.Key})
+				}
+				if rs[kv.Key] != nil && !ignored.skipFill(f, kv.Key, kv.Value) {
+					keyValues[i].Value = rs[kv.Key](newRuleArgs(scope, f, kv.Key, kv.Value))
 				}
 			}
 			for _, kv := range keyValues {
@@ -187,10 +256,13 @@ func fieldsTagFill(fields []*ast.Field, keySet map[string]struct{}, ruleSet map[
 			}
 
 			for k, rule := range missingRuleSet {
+				if ignored.skipFill(f, k, "") {
+					continue
+				}
 				appendKeyValues = append(appendKeyValues, KeyValue{
 					Key:   k,
 					quote: quote,
-					Value: rule(newRuleArgs(f, "")),
+					Value: rule(newRuleArgs(scope, f, k, "")),
 				})
 			}
 			sort.Slice(appendKeyValues, func(i, j int) bool {
@@ -207,6 +279,7 @@ func fieldsTagFill(fields []*ast.Field, keySet map[string]struct{}, ruleSet map[
 		}
 
 	}
+	return flagged
 }
 
 func keySetClone(keySet map[string]struct{}) map[string]struct{} {
@@ -304,6 +377,14 @@ func parseFieldRuleSingle(r string) (tagFieldRule, error) {
 			return func(args *ruleFuncArgs) (newTagName string) {
 				return snakeConvert(subRuleList[0](args))
 			}, nil
+		case "screaming_snake":
+			subRuleList, err := parseFieldMultiRule(argsStr, 1)
+			if err != nil {
+				return nil, err
+			}
+			return func(args *ruleFuncArgs) (newTagName string) {
+				return screamingSnakeConvert(subRuleList[0](args))
+			}, nil
 		case "upper_camel":
 			subRuleList, err := parseFieldMultiRule(argsStr, 1)
 			if err != nil {
@@ -320,6 +401,16 @@ func parseFieldRuleSingle(r string) (tagFieldRule, error) {
 			return func(args *ruleFuncArgs) (newTagName string) {
 				return lowerCamelConvert(subRuleList[0](args))
 			}, nil
+		case "lookup":
+			return parseLookupRule(argsStr)
+		case "prefix":
+			subRuleList, err := parseFieldMultiRule(argsStr, 2)
+			if err != nil {
+				return nil, err
+			}
+			return func(args *ruleFuncArgs) (newTagName string) {
+				return prefixValue(subRuleList[0](args), subRuleList[1], args, 0)
+			}, nil
 		case "or":
 			subRuleList, err := parseFieldMultiRule(argsStr, 2)
 			if err != nil {
@@ -343,6 +434,39 @@ func parseFieldRuleSingle(r string) (tagFieldRule, error) {
 			return func(args *ruleFuncArgs) (newTagName string) {
 				return getFieldName(args.Field)
 			}, nil
+		} else if r == ":struct" { // fetch struct name
+			return func(args *ruleFuncArgs) (newTagName string) {
+				return args.Scope.StructName()
+			}, nil
+		} else if r == ":parent" { // fetch the field name of nested struct
+			return func(args *ruleFuncArgs) (newTagName string) {
+				if _, field := args.Scope.Up(); field != nil {
+					return getFieldOrTypeName(field)
+				}
+				return ""
+			}, nil
+		} else if r == ":path" { // fetch the dotted field names from top struct
+			return func(args *ruleFuncArgs) (newTagName string) {
+				return strings.Join(append(args.Scope.FieldPath(), getFieldName(args.Field)), ".")
+			}, nil
+		} else if r == ":pkg" { // fetch package name
+			return func(args *ruleFuncArgs) (newTagName string) {
+				if args.Scope == nil {
+					return ""
+				}
+				return args.Scope.Pkg
+			}, nil
+		} else if r == ":index" { // fetch the index of field in struct
+			return func(args *ruleFuncArgs) (newTagName string) {
+				if i := args.Scope.FieldIndex(args.Field); i != -1 {
+					return strconv.Itoa(i)
+				}
+				return ""
+			}, nil
+		} else if r == ":comment" { // fetch field doc or line comment
+			return func(args *ruleFuncArgs) (newTagName string) {
+				return fieldComment(args.Field)
+			}, nil
 		} else if r == ":tag" { // fetch field name
 			return func(args *ruleFuncArgs) (newTagName string) {
 				return args.OldTag
@@ -381,9 +505,10 @@ func findNextQuote(s string, i int, quote byte) int {
 	return -1
 }
 
+// splitWithoutQuote splits s with key, ignore the key in quote and brackets e.g the args of nested function
 func splitWithoutQuote(s string, key byte) ([]string, error) {
 	var sub []string
-	pre := 0
+	pre, depth := 0, 0
 	for i := 0; i < len(s); i++ {
 		c := s[i]
 		if s[i] == '"' || s[i] == '\'' {
@@ -392,7 +517,11 @@ func splitWithoutQuote(s string, key byte) ([]string, error) {
 				return nil, ErrUnclosedQuote
 			}
 			i = nextQuote
-		} else if s[i] == key {
+		} else if s[i] == '(' {
+			depth++
+		} else if s[i] == ')' && depth > 0 {
+			depth--
+		} else if s[i] == key && depth == 0 {
 			sub = append(sub, s[pre:i])
 			pre = i + 1
 
@@ -498,12 +627,48 @@ func parseFieldRule(s string) (map[string]tagFieldRule, error) {
 	return rules, nil
 }
 
-func newTagFill(f *ast.File, fs *token.FileSet, rule string) (*tagFiller, error) {
+// prefixValue returns the value of rule joined to the value of parent field with sep,
+// the existing name of parent is used first, or the rule is applied to parent recursively
+func prefixValue(sep string, rule tagFieldRule, args *ruleFuncArgs, depth int) string {
+	name := rule(args)
+	parentScope, parentField := args.Scope.Up()
+	if parentField == nil || depth >= maxScopeDepth {
+		return name
+	}
+	parent := ""
+	if parentField.Tag != nil {
+		if _, keyValues, err := ParseTag(parentField.Tag.Value); err == nil {
+			for _, kv := range keyValues {
+				if kv.Key == args.Key {
+					parent = strings.SplitN(kv.Value, ",", 2)[0]
+				}
+			}
+		}
+	}
+	if parent == "" {
+		parent = prefixValue(sep, rule, newRuleArgs(parentScope, parentField, args.Key, ""), depth+1)
+	}
+	if parent == "" || parent == "-" {
+		return name
+	}
+	return parent + sep + name
+}
+
+func newTagFill(f *ast.File, fs *token.FileSet, filename, rule, ignored string) (*tagFiller, error) {
+	// the rule isn't from config, the mapping files of lookup are relative to source file
+	if lookupBase == "" {
+		lookupBase = filepath.Dir(filename)
+		defer func() { lookupBase = "" }()
+	}
 	ruleSet, err := parseFieldRule(rule)
 	if err != nil {
 		return nil, err
 	}
-	s := &tagFiller{fs: fs, f: f, ruleSet: ruleSet}
+	ignoredModes, err := parseIgnoreModes(ignored)
+	if err != nil {
+		return nil, err
+	}
+	s := &tagFiller{fs: fs, f: f, filename: filename, ruleSet: ruleSet, ignored: ignoredModes}
 	return s, nil
 }
 
@@ -540,6 +705,40 @@ func snakeConvert(name string) string {
 	return string(convert)
 }
 
+// screamingSnakeConvert converts name to upper snake case, the dots of path become underscore
+// e.g Server.HTTP.Port => SERVER_HTTP_PORT
+func screamingSnakeConvert(name string) string {
+	return strings.ToUpper(strings.Replace(snakeConvert(name), ".", "_", -1))
+}
+
+// fieldComment returns the text of field doc, or line comment if there is no doc,
+// the lines are joined with space and the text is escaped as tag value
+func fieldComment(field *ast.Field) string {
+	group := field.Doc
+	if group == nil {
+		group = field.Comment
+	}
+	if group == nil {
+		return ""
+	}
+	return escapeTagValue(strings.Join(strings.Fields(group.Text()), " "))
+}
+
+// escapeTagValue quotes the text by go syntax without the surrounding quotes,
+// the backtick can't be in raw string tag so it's written as \x60
+func escapeTagValue(s string) string {
+	quoted := strconv.Quote(s)
+	return strings.Replace(quoted[1:len(quoted)-1], "`", `\x60`, -1)
+}
+
+// unescapeTagValue is the reverse of escapeTagValue, the value is returned as it is if it's not valid
+func unescapeTagValue(s string) string {
+	if text, err := strconv.Unquote(`"` + s + `"`); err == nil {
+		return text
+	}
+	return s
+}
+
 func upperCamelConvert(name string) string {
 	if len(name) == 0 {
 		return ""
@@ -34,13 +34,14 @@ func TestSnakeConvert(t *testing.T) {
 	assert.Equal(t, snakeConvert("NameHTTPtest"), "name_http_test")
 	assert.Equal(t, snakeConvert("IDandValue"), "id_and_value")
 	assert.Equal(t, snakeConvert("toyorm.User.field"), "toyorm.user.field")
+	assert.Equal(t, screamingSnakeConvert("Server.HTTP.Port"), "SERVER_HTTP_PORT")
 }
 
 func TestParseFieldRule(t *testing.T) {
 	testFieldArgs := func(name string, oldTag string) *ruleFuncArgs {
-		return newRuleArgs(&ast.Field{
+		return newRuleArgs(nil, &ast.Field{
 			Names: []*ast.Ident{{Name: name}},
-		}, oldTag)
+		}, "", oldTag)
 	}
 	{
 		rules, err := parseFieldRule("json=snake(:field)|yaml=lower_camel(:field)")
@@ -82,3 +83,11 @@ func TestParseFieldRule(t *testing.T) {
 	}
 
 }
+
+func TestEscapeTagValue(t *testing.T) {
+	text := "the \"unique\" id, see `users.id` or C:\\id"
+	escaped := escapeTagValue(text)
+	assert.Equal(t, `the \"unique\" id, see \x60users.id\x60 or C:\\id`, escaped)
+	assert.Equal(t, text, unescapeTagValue(escaped))
+	assert.Equal(t, `bad \q`, unescapeTagValue(`bad \q`))
+}
@@ -0,0 +1,184 @@
+/*
+ * Copyright 2020 bigpigeon. All rights reserved.
+ * Use of this source code is governed by a MIT style
+ * license that can be found in the LICENSE file.
+ *
+ */
+
+package main
+
+import (
+	"strings"
+)
+
+// TagOption is a part of tag value e.g omitempty in `json:"name,omitempty"`
+// or type:varchar(64) in `gorm:"type:varchar(64)"`
+type TagOption struct {
+	Key      string
+	Value    string
+	HasValue bool
+
+	raw   string // the origin text of option, keep it if the option isn't modified
+	rawOf string // the option text when parsed
+}
+
+// TagValue is the structured tag value, Name is empty if grammar has no name part
+type TagValue struct {
+	Name    string
+	Options []TagOption
+
+	rawName string
+}
+
+// Option returns the first option with key
+func (v *TagValue) Option(key string) (TagOption, bool) {
+	for _, opt := range v.Options {
+		if opt.Key == key {
+			return opt, true
+		}
+	}
+	return TagOption{}, false
+}
+
+// TagGrammar parses the value of tag key to structured parts and prints it back
+type TagGrammar interface {
+	Parse(value string) (*TagValue, error)
+	Print(v *TagValue) string
+}
+
+// listGrammar is the grammar of value that split by separator,
+// the first part is name if named, other parts are options, option is key or key<kvSep>value
+type listGrammar struct {
+	named bool
+	sep   byte
+	kvSep byte
+}
+
+func (g listGrammar) optionString(opt TagOption) string {
+	if opt.HasValue {
+		return opt.Key + string(g.kvSep) + opt.Value
+	}
+	return opt.Key
+}
+
+func (g listGrammar) Parse(value string) (*TagValue, error) {
+	parts, err := splitTagValue(value, g.sep)
+	if err != nil {
+		return nil, err
+	}
+	v := &TagValue{}
+	if g.named {
+		if len(parts) != 0 {
+			v.rawName = parts[0]
+			v.Name = strings.TrimSpace(parts[0])
+			parts = parts[1:]
+		}
+	}
+	for _, part := range parts {
+		opt := TagOption{raw: part}
+		if i := strings.IndexByte(part, g.kvSep); i != -1 {
+			opt.Key = strings.TrimSpace(part[:i])
+			opt.Value = strings.TrimSpace(part[i+1:])
+			opt.HasValue = true
+		} else {
+			opt.Key = strings.TrimSpace(part)
+		}
+		opt.rawOf = g.optionString(opt)
+		v.Options = append(v.Options, opt)
+	}
+	return v, nil
+}
+
+func (g listGrammar) Print(v *TagValue) string {
+	var parts []string
+	if g.named {
+		if v.rawName != "" && strings.TrimSpace(v.rawName) == v.Name {
+			parts = append(parts, v.rawName)
+		} else {
+			parts = append(parts, v.Name)
+		}
+	}
+	for _, opt := range v.Options {
+		if s := g.optionString(opt); opt.raw != "" && s == opt.rawOf {
+			parts = append(parts, opt.raw)
+		} else {
+			parts = append(parts, s)
+		}
+	}
+	return strings.Join(parts, string(g.sep))
+}
+
+// splitTagValue splits the value with sep, ignore the sep in single quote and brackets
+// e.g type:enum('a;b');not null => [type:enum('a;b') not null]
+func splitTagValue(s string, sep byte) ([]string, error) {
+	if s == "" {
+		return nil, nil
+	}
+	var parts []string
+	depth, pre := 0, 0
+	for i := 0; i < len(s); i++ {
+		switch c := s[i]; c {
+		case '\'':
+			next := findNextQuote(s, i+1, c)
+			if next == -1 {
+				return nil, ErrUnclosedQuote
+			}
+			i = next
+		case '(':
+			depth++
+		case ')':
+			if depth > 0 {
+				depth--
+			}
+		case sep:
+			if depth == 0 {
+				parts = append(parts, s[pre:i])
+				pre = i + 1
+			}
+		}
+	}
+	return append(parts, s[pre:]), nil
+}
+
+var (
+	// e.g json:"name,omitempty,string"
+	commaNamedGrammar = listGrammar{named: true, sep: ',', kvSep: '='}
+	// e.g validate:"required,min=1,max=64"
+	commaGrammar = listGrammar{sep: ',', kvSep: '='}
+	// e.g gorm:"column:name;type:varchar(64);not null"
+	gormGrammar = listGrammar{sep: ';', kvSep: ':'}
+)
+
+var tagGrammars = map[string]TagGrammar{
+	"json":         commaNamedGrammar,
+	"xml":          commaNamedGrammar,
+	"yaml":         commaNamedGrammar,
+	"bson":         commaNamedGrammar,
+	"toml":         commaNamedGrammar,
+	"mapstructure": commaNamedGrammar,
+	"db":           commaNamedGrammar,
+	"validate":     commaGrammar,
+	"binding":      commaGrammar,
+	"protobuf":     commaGrammar,
+	"gorm":         gormGrammar,
+}
+
+// RegisterTagGrammar registers the grammar of tag key, it replaces the registered grammar
+func RegisterTagGrammar(key string, g TagGrammar) {
+	tagGrammars[key] = g
+}
+
+// LookupTagGrammar returns the grammar of tag key, nil if not registered
+func LookupTagGrammar(key string) TagGrammar {
+	return tagGrammars[key]
+}
+
+// ParseTagValue parses the value of key with its grammar
+func ParseTagValue(key, value string) (*TagValue, TagGrammar, error) {
+	g := LookupTagGrammar(key)
+	if g == nil {
+		return nil, nil, nil
+	}
+	v, err := g.Parse(value)
+	return v, g, err
+}
@@ -0,0 +1,64 @@
+/*
+ * Copyright 2020 bigpigeon. All rights reserved.
+ * Use of this source code is governed by a MIT style
+ * license that can be found in the LICENSE file.
+ *
+ */
+
+package main
+
+import (
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"testing"
+)
+
+func TestTagGrammarParse(t *testing.T) {
+	{
+		v, g, err := ParseTagValue("json", "name, omitempty,string")
+		require.NoError(t, err)
+		assert.Equal(t, "name", v.Name)
+		require.Len(t, v.Options, 2)
+		assert.Equal(t, "omitempty", v.Options[0].Key)
+		assert.Equal(t, "string", v.Options[1].Key)
+		assert.Equal(t, "name, omitempty,string", g.Print(v))
+		v.Options[0].Key = "omitzero"
+		assert.Equal(t, "name,omitzero,string", g.Print(v))
+	}
+	{
+		v, g, err := ParseTagValue("gorm", "column:name;type:enum('a;b');not null")
+		require.NoError(t, err)
+		assert.Equal(t, "", v.Name)
+		require.Len(t, v.Options, 3)
+		opt, ok := v.Option("type")
+		assert.True(t, ok)
+		assert.Equal(t, "enum('a;b')", opt.Value)
+		assert.Equal(t, "not null", v.Options[2].Key)
+		assert.False(t, v.Options[2].HasValue)
+		assert.Equal(t, "column:name;type:enum('a;b');not null", g.Print(v))
+	}
+	{
+		v, g, err := ParseTagValue("validate", "required,min=1,max=64")
+		require.NoError(t, err)
+		opt, ok := v.Option("min")
+		assert.True(t, ok)
+		assert.Equal(t, "1", opt.Value)
+		assert.Equal(t, "required,min=1,max=64", g.Print(v))
+	}
+	{
+		v, g, err := ParseTagValue("json", ",omitempty")
+		require.NoError(t, err)
+		assert.Equal(t, "", v.Name)
+		assert.Equal(t, ",omitempty", g.Print(v))
+	}
+	{
+		v, g, err := ParseTagValue("desc", "some text")
+		require.NoError(t, err)
+		assert.Nil(t, v)
+		assert.Nil(t, g)
+	}
+	{
+		_, _, err := ParseTagValue("gorm", "default:'abc")
+		assert.Equal(t, ErrUnclosedQuote, err)
+	}
+}
@@ -0,0 +1,224 @@
+/*
+ * Copyright 2020 bigpigeon. All rights reserved.
+ * Use of this source code is governed by a MIT style
+ * license that can be found in the LICENSE file.
+ *
+ */
+
+package main
+
+import (
+	"errors"
+	"go/ast"
+	"go/token"
+	"sort"
+	"strings"
+)
+
+type tagNormalizeOp int
+
+const (
+	// remove the whitespace around separator and empty options
+	normalizeSpace tagNormalizeOp = 1 << iota
+	// remove the repeated options
+	normalizeDedup
+	// sort the options in canonical order
+	normalizeOrder
+
+	normalizeAll = normalizeSpace | normalizeDedup | normalizeOrder
+)
+
+var normalizeOpNames = map[string]tagNormalizeOp{
+	"space": normalizeSpace,
+	"dedup": normalizeDedup,
+	"order": normalizeOrder,
+}
+
+// tagOptionOrder is the canonical order of options, the unknown options keep its order after them.
+// the option order of validate/binding is meaningful, so they are not here
+var tagOptionOrder = map[string][]string{
+	"json":         {"omitempty", "omitzero", "string", "inline"},
+	"xml":          {"attr", "chardata", "cdata", "innerxml", "comment", "any", "omitempty"},
+	"yaml":         {"omitempty", "flow", "inline"},
+	"bson":         {"omitempty", "minsize", "truncate", "inline"},
+	"toml":         {"omitempty", "omitzero", "inline", "multiline"},
+	"mapstructure": {"squash", "remain", "omitempty"},
+	"gorm": {"column", "type", "size", "primarykey", "primary_key", "unique", "default", "precision", "scale",
+		"not null", "autoincrement", "autoincrementincrement", "embedded", "embeddedprefix",
+		"autocreatetime", "autoupdatetime", "index", "uniqueindex", "check", "<-", "->", "-", "comment",
+		"serializer", "foreignkey", "references", "polymorphic", "polymorphicvalue", "many2many",
+		"joinforeignkey", "joinreferences", "constraint"},
+}
+
+// parseNormalizeRule parses the normalize rule e.g json=space,dedup|gorm=order|*
+// key without operations means all operations, '*' means all keys have grammar
+func parseNormalizeRule(s string) (map[string]tagNormalizeOp, error) {
+	rules := map[string]tagNormalizeOp{}
+	for _, cell := range strings.Split(s, "|") {
+		cell = strings.TrimSpace(cell)
+		if cell == "" {
+			continue
+		}
+		keyOps := strings.SplitN(cell, "=", 2)
+		key := strings.TrimSpace(keyOps[0])
+		if len(keyOps) == 1 {
+			rules[key] = normalizeAll
+			continue
+		}
+		var ops tagNormalizeOp
+		for _, name := range strings.Split(keyOps[1], ",") {
+			op, ok := normalizeOpNames[strings.TrimSpace(name)]
+			if !ok {
+				return nil, errors.New("invalid normalize operation (" + name + ") please check 'normalize' arg")
+			}
+			ops |= op
+		}
+		rules[key] = ops
+	}
+	return rules, nil
+}
+
+type tagNormalizer struct {
+	f      *ast.File
+	fs     *token.FileSet
+	Err    error
+	rules  map[string]tagNormalizeOp
+	fields []*ast.Field
+}
+
+func (s *tagNormalizer) Scan() error {
+	ast.Walk(s, s.f)
+	return s.Err
+}
+
+func (s *tagNormalizer) Execute() error {
+	for _, field := range s.fields {
+		err := normalizeField(field, s.rules)
+		if err != nil {
+			s.Err = NewAstError(s.fs, field.Tag, err)
+			return s.Err
+		}
+	}
+	return s.Err
+}
+
+func (s *tagNormalizer) Visit(node ast.Node) ast.Visitor {
+	cmap := ast.NewCommentMap(s.fs, node, s.f.Comments)
+	visit := newTopVisit(cmap, s.executor)
+	return visit.Visit(node)
+}
+
+func (s *tagNormalizer) executor(name string, comments []*ast.CommentGroup, n *ast.StructType) {
+	if n.Fields != nil {
+		for _, field := range n.Fields.List {
+			if fieldFilter(getFieldName(field)) && field.Tag != nil {
+				s.fields = append(s.fields, field)
+			}
+		}
+	}
+}
+
+func normalizeField(field *ast.Field, rules map[string]tagNormalizeOp) error {
+	quote, keyValues, err := ParseTag(field.Tag.Value)
+	if err != nil {
+		return err
+	}
+	changed := false
+	for i, kv := range keyValues {
+		ops, ok := rules[kv.Key]
+		if !ok {
+			ops = rules["*"]
+		}
+		if ops == 0 {
+			continue
+		}
+		v, g, err := ParseTagValue(kv.Key, kv.Value)
+		if err != nil {
+			return err
+		}
+		if v == nil {
+			continue
+		}
+		normalizeTagValue(kv.Key, v, ops)
+		if value := g.Print(v); value != kv.Value {
+			keyValues[i].Value = value
+			changed = true
+		}
+	}
+	if changed {
+		var keyValuesRaw []string
+		for _, kv := range keyValues {
+			keyValuesRaw = append(keyValuesRaw, kv.String())
+		}
+		field.Tag.Value = quote + strings.Join(keyValuesRaw, " ") + quote
+		field.Tag.ValuePos = 0
+	}
+	return nil
+}
+
+func normalizeTagValue(key string, v *TagValue, ops tagNormalizeOp) {
+	if ops&normalizeSpace != 0 {
+		v.rawName = ""
+		var options []TagOption
+		for _, opt := range v.Options {
+			if opt.Key == "" && !opt.HasValue {
+				continue
+			}
+			opt.raw = ""
+			options = append(options, opt)
+		}
+		// the name "-" with empty option e.g json:"-," isn't the ignore marker, keep the empty option
+		if v.Name == "-" && len(options) == 0 && len(v.Options) != 0 {
+			options = append(options, TagOption{})
+		}
+		v.Options = options
+	}
+	if ops&normalizeDedup != 0 {
+		var options []TagOption
+		seen := map[TagOption]bool{}
+		for _, opt := range v.Options {
+			id := TagOption{Key: opt.Key, Value: opt.Value, HasValue: opt.HasValue}
+			if seen[id] {
+				continue
+			}
+			seen[id] = true
+			options = append(options, opt)
+		}
+		v.Options = options
+	}
+	if order := tagOptionOrder[key]; ops&normalizeOrder != 0 && order != nil {
+		rank := func(opt TagOption) int {
+			for i, o := range order {
+				if strings.EqualFold(o, opt.Key) {
+					return i
+				}
+			}
+			return len(order)
+		}
+		// the empty options stay where they are, the others are trimmed and ordered in their slots
+		var slots []int
+		var options []TagOption
+		for i, opt := range v.Options {
+			if opt.Key == "" && !opt.HasValue {
+				continue
+			}
+			opt.raw = ""
+			slots = append(slots, i)
+			options = append(options, opt)
+		}
+		sort.SliceStable(options, func(i, j int) bool {
+			return rank(options[i]) < rank(options[j])
+		})
+		for i, slot := range slots {
+			v.Options[slot] = options[i]
+		}
+	}
+}
+
+func newTagNormalize(f *ast.File, fs *token.FileSet, rule string) (*tagNormalizer, error) {
+	rules, err := parseNormalizeRule(rule)
+	if err != nil {
+		return nil, err
+	}
+	return &tagNormalizer{f: f, fs: fs, rules: rules}, nil
+}
@@ -0,0 +1,165 @@
+/*
+ * Copyright 2020 bigpigeon. All rights reserved.
+ * Use of this source code is governed by a MIT style
+ * license that can be found in the LICENSE file.
+ *
+ */
+
+package main
+
+import (
+	"go/ast"
+	"go/token"
+	"strconv"
+	"strings"
+)
+
+// parseKeyList parses the comma separated keys, nil if s is empty
+func parseKeyList(s string) map[string]bool {
+	var keys map[string]bool
+	for _, key := range strings.Split(s, ",") {
+		key = strings.TrimSpace(key)
+		if key == "" {
+			continue
+		}
+		if keys == nil {
+			keys = map[string]bool{}
+		}
+		keys[key] = true
+	}
+	return keys
+}
+
+// tagKeyRemover removes the keys in remove, and reports the keys not in allow,
+// they are removed too if fix is enabled, the empty values are reported and removed if prune is enabled,
+// prune only removes the empty values of pruneKeys if it isn't nil. the tag becomes empty is removed
+type tagKeyRemover struct {
+	f           *ast.File
+	fs          *token.FileSet
+	remove      map[string]bool
+	allow       map[string]bool
+	prune       bool
+	pruneKeys   map[string]bool
+	fix         bool
+	fields      []*ast.Field
+	diagnostics []tagDiagnostic
+}
+
+// Scan removes the keys of fields
+func (s *tagKeyRemover) Scan() error {
+	ast.Walk(s, s.f)
+	for _, field := range s.fields {
+		keys := s.removedKeys(field)
+		if len(keys) == 0 && !(s.prune && field.Tag.Value[1:len(field.Tag.Value)-1] == "") {
+			continue
+		}
+		tag, empty, err := removeTagKeys(field.Tag.Value, keys)
+		if err != nil {
+			return NewAstError(s.fs, field.Tag, err)
+		}
+		if empty {
+			field.Tag = nil
+			continue
+		}
+		field.Tag.Value = tag
+		field.Tag.ValuePos = 0
+	}
+	return nil
+}
+
+func (s *tagKeyRemover) Execute() error {
+	return nil
+}
+
+// removedKeys returns the keys of field should be removed
+func (s *tagKeyRemover) removedKeys(field *ast.Field) map[string]bool {
+	_, keyValues, err := ParseTag(field.Tag.Value)
+	if err != nil {
+		return nil
+	}
+	keys := map[string]bool{}
+	for _, kv := range keyValues {
+		if s.remove[kv.Key] || (s.fix && s.allow != nil && !s.allow[kv.Key]) || s.pruned(kv) {
+			keys[kv.Key] = true
+		}
+	}
+	return keys
+}
+
+// pruned reports whether kv is the empty value should be pruned
+func (s *tagKeyRemover) pruned(kv KeyValue) bool {
+	return s.prune && kv.Value == "" && (s.pruneKeys == nil || s.pruneKeys[kv.Key])
+}
+
+func (s *tagKeyRemover) Visit(node ast.Node) ast.Visitor {
+	cmap := ast.NewCommentMap(s.fs, node, s.f.Comments)
+	visit := newTopVisit(cmap, s.executor)
+	return visit.Visit(node)
+}
+
+func (s *tagKeyRemover) executor(name string, comments []*ast.CommentGroup, n *ast.StructType) {
+	if n.Fields == nil {
+		return
+	}
+	var allowed []string
+	for key := range s.allow {
+		allowed = append(allowed, key)
+	}
+	spec := tagOptionSpec{options: allowed}
+	for _, field := range n.Fields.List {
+		if field.Tag == nil || !fieldFilter(getFieldOrTypeName(field)) {
+			continue
+		}
+		_, keyValues, err := ParseTag(field.Tag.Value)
+		if err != nil {
+			// reported by tag doctor
+			continue
+		}
+		s.fields = append(s.fields, field)
+		if len(keyValues) == 0 {
+			s.diagnostics = append(s.diagnostics, newTagDiagnostic(s.fs, field.Tag, "empty tag"))
+		}
+		for _, kv := range keyValues {
+			if kv.Value == "" {
+				diag := newTagDiagnostic(s.fs, field.Tag, "key "+strconv.Quote(kv.Key)+" has empty value")
+				if fixed, empty, err := removeTagKeys(field.Tag.Value, map[string]bool{kv.Key: true}); err == nil && !empty {
+					diag.Fix, diag.FixTitle = fixed, "Remove empty key "+strconv.Quote(kv.Key)
+				}
+				s.diagnostics = append(s.diagnostics, diag)
+			}
+			if s.allow == nil || s.allow[kv.Key] {
+				continue
+			}
+			msg := "key " + strconv.Quote(kv.Key) + " is not allowed"
+			// the known key e.g bson is obsolete rather than a typo
+			if suggest := spec.suggest(kv.Key); suggest != "" && !knownTagKey(kv.Key) {
+				msg += ", did you mean " + strconv.Quote(suggest) + "?"
+			}
+			diag := newTagDiagnostic(s.fs, field.Tag, msg)
+			// the tag can't be removed by replacing the literal
+			if fixed, empty, err := removeTagKeys(field.Tag.Value, map[string]bool{kv.Key: true}); err == nil && !empty {
+				diag.Fix, diag.FixTitle = fixed, "Remove key "+strconv.Quote(kv.Key)
+			}
+			s.diagnostics = append(s.diagnostics, diag)
+		}
+	}
+}
+
+// knownTagKey reports whether key is used by the known encoders or libraries
+func knownTagKey(key string) bool {
+	_, spec := tagOptionSpecs[key]
+	_, freeze := freezeRules[key]
+	return spec || freeze
+}
+
+func newTagKeyRemove(f *ast.File, fs *token.FileSet, remove, allow string, prune bool, pruneKeys string, fix bool) *tagKeyRemover {
+	return &tagKeyRemover{
+		f:         f,
+		fs:        fs,
+		remove:    parseKeyList(remove),
+		allow:     parseKeyList(allow),
+		prune:     prune,
+		pruneKeys: parseKeyList(pruneKeys),
+		fix:       fix,
+	}
+}
@@ -0,0 +1,61 @@
+/*
+ * Copyright 2020 bigpigeon. All rights reserved.
+ * Use of this source code is governed by a MIT style
+ * license that can be found in the LICENSE file.
+ *
+ */
+
+package main
+
+import (
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"testing"
+)
+
+func TestAllowKeysLint(t *testing.T) {
+	resetFlags()
+	initParserMode()
+	*allowKeys = "json,yaml"
+	defer resetFlags()
+	src := "package main\n\ntype User struct {\n\tID   int    `jsno:\"id\" yaml:\"id\"`\n\tName string `bson:\"name\"`\n}\n"
+	diagnostics, err := lintSource("lint.go", []byte(src))
+	require.NoError(t, err)
+	var messages []string
+	for _, diag := range diagnostics {
+		messages = append(messages, diag.String())
+	}
+	assert.Equal(t, []string{
+		`lint.go:4:14: key "jsno" is not allowed, did you mean "json"?`,
+		`lint.go:5:14: key "bson" is not allowed`,
+	}, messages)
+	assert.Equal(t, "`yaml:\"id\"`", diagnostics[0].Fix)
+	// the tag becomes empty can't be fixed by replacing it
+	assert.Equal(t, "", diagnostics[1].Fix)
+}
+
+func TestEditDistance(t *testing.T) {
+	assert.Equal(t, 1, editDistance("jsno", "json"))
+	assert.Equal(t, 1, editDistance("bson", "json"))
+	assert.Equal(t, 3, editDistance("", "abc"))
+	assert.Equal(t, 2, editDistance("omitempty", "omitmepyt"))
+}
+
+func TestEmptyValueLint(t *testing.T) {
+	resetFlags()
+	initParserMode()
+	src := "package main\n\ntype User struct {\n\tID   int    `json:\"id\" xml:\"\"`\n\tName string `gorm:\"\"`\n\tAge  int    ``\n}\n"
+	diagnostics, err := lintSource("lint.go", []byte(src))
+	require.NoError(t, err)
+	var messages []string
+	for _, diag := range diagnostics {
+		messages = append(messages, diag.String())
+	}
+	assert.Equal(t, []string{
+		`lint.go:4:14: key "xml" has empty value`,
+		`lint.go:5:14: key "gorm" has empty value`,
+		`lint.go:6:14: empty tag`,
+	}, messages)
+	assert.Equal(t, "`json:\"id\"`", diagnostics[0].Fix)
+	assert.Equal(t, "", diagnostics[1].Fix)
+}
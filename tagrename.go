@@ -0,0 +1,225 @@
+/*
+ * Copyright 2020 bigpigeon. All rights reserved.
+ * Use of this source code is governed by a MIT style
+ * license that can be found in the LICENSE file.
+ *
+ */
+
+package main
+
+import (
+	"errors"
+	"fmt"
+	"go/ast"
+	"go/token"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+)
+
+// migrateOut is where the values can't be translated are reported
+var migrateOut io.Writer = os.Stderr
+
+// keyMigration moves the value of From to Key, Option is the option of From grammar used as value,
+// empty Option means the value is kept as it is
+type keyMigration struct {
+	Key    string
+	From   string
+	Option string
+}
+
+// parseKeyRenames parses the comma separated renames e.g form=query,mapstructure=koanf
+func parseKeyRenames(s string) ([]keyMigration, error) {
+	var migrations []keyMigration
+	for _, item := range strings.Split(s, ",") {
+		item = strings.TrimSpace(item)
+		if item == "" {
+			continue
+		}
+		oldNew := strings.Split(item, "=")
+		if len(oldNew) != 2 || strings.TrimSpace(oldNew[0]) == "" || strings.TrimSpace(oldNew[1]) == "" {
+			return nil, errors.New("invalid rename " + item + " please check 'rename-key' arg, expect old=new")
+		}
+		migrations = append(migrations, keyMigration{Key: strings.TrimSpace(oldNew[1]), From: strings.TrimSpace(oldNew[0])})
+	}
+	return migrations, nil
+}
+
+// parseKeyMigrations parses the comma separated migrations e.g db=gorm.column,query=form
+func parseKeyMigrations(s string) ([]keyMigration, error) {
+	var migrations []keyMigration
+	for _, item := range strings.Split(s, ",") {
+		item = strings.TrimSpace(item)
+		if item == "" {
+			continue
+		}
+		keyFrom := strings.Split(item, "=")
+		if len(keyFrom) != 2 || strings.TrimSpace(keyFrom[0]) == "" || strings.TrimSpace(keyFrom[1]) == "" {
+			return nil, errors.New("invalid migration " + item + " please check 'migrate' arg, expect new=old.option")
+		}
+		m := keyMigration{Key: strings.TrimSpace(keyFrom[0]), From: strings.TrimSpace(keyFrom[1])}
+		if i := strings.IndexByte(m.From, '.'); i != -1 {
+			m.From, m.Option = m.From[:i], m.From[i+1:]
+			if LookupTagGrammar(m.From) == nil {
+				return nil, errors.New("key " + m.From + " has no grammar, can't migrate its option " + m.Option)
+			}
+		}
+		migrations = append(migrations, m)
+	}
+	return migrations, nil
+}
+
+// tagKeyRenamer moves the values to the new keys, the old key is removed unless
+// some of its value can't be translated
+type tagKeyRenamer struct {
+	f          *ast.File
+	fs         *token.FileSet
+	migrations []keyMigration
+}
+
+// Scan renames the keys of fields
+func (s *tagKeyRenamer) Scan() error {
+	if len(s.migrations) == 0 {
+		return nil
+	}
+	ast.Walk(s, s.f)
+	return nil
+}
+
+func (s *tagKeyRenamer) Execute() error {
+	return nil
+}
+
+func (s *tagKeyRenamer) Visit(node ast.Node) ast.Visitor {
+	cmap := ast.NewCommentMap(s.fs, node, s.f.Comments)
+	visit := newTopVisit(cmap, s.executor)
+	return visit.Visit(node)
+}
+
+func (s *tagKeyRenamer) executor(name string, comments []*ast.CommentGroup, n *ast.StructType) {
+	if n.Fields == nil {
+		return
+	}
+	for _, field := range n.Fields.List {
+		if field.Tag == nil || !fieldFilter(getFieldOrTypeName(field)) {
+			continue
+		}
+		quote, keyValues, err := ParseTag(field.Tag.Value)
+		if err != nil {
+			// reported by tag doctor
+			continue
+		}
+		if migrated, changed := s.migrate(field, quote, keyValues); changed {
+			field.Tag.Value = joinTag(quote, migrated)
+			field.Tag.ValuePos = 0
+		}
+	}
+}
+
+// migrate returns the key values after migration, the new keys take the place of old key
+func (s *tagKeyRenamer) migrate(field *ast.Field, quote string, keyValues []KeyValue) ([]KeyValue, bool) {
+	exist := map[string]bool{}
+	for _, kv := range keyValues {
+		exist[kv.Key] = true
+	}
+	fieldName := getFieldOrTypeName(field)
+	// the new key values of old key, and whether the old key is kept
+	added := map[string][]KeyValue{}
+	consumed := map[string]map[string]bool{}
+	kept := map[string]bool{}
+	for _, m := range s.migrations {
+		var from KeyValue
+		found := false
+		for _, kv := range keyValues {
+			if kv.Key == m.From {
+				from, found = kv, true
+				break
+			}
+		}
+		if !found {
+			continue
+		}
+		if exist[m.Key] {
+			s.warn(field, fmt.Sprintf("field %s already has key %s, keep %s", fieldName, m.Key, m.From))
+			kept[m.From] = true
+			continue
+		}
+		value := from.Value
+		if m.Option != "" {
+			v, _, err := ParseTagValue(m.From, from.Value)
+			if err != nil {
+				s.warn(field, fmt.Sprintf("field %s: %s value can't be parsed: %s, keep %s", fieldName, m.From, err, m.From))
+				kept[m.From] = true
+				continue
+			}
+			opt, ok := v.Option(m.Option)
+			if !ok || !opt.HasValue {
+				s.warn(field, fmt.Sprintf("field %s has no %s option %s, keep %s", fieldName, m.From, m.Option, m.From))
+				kept[m.From] = true
+				continue
+			}
+			value = opt.Value
+			if consumed[m.From] == nil {
+				consumed[m.From] = map[string]bool{}
+			}
+			consumed[m.From][m.Option] = true
+		}
+		exist[m.Key] = true
+		added[m.From] = append(added[m.From], KeyValue{Key: m.Key, quote: quote, Value: value})
+	}
+	if len(added) == 0 {
+		return nil, false
+	}
+	var result []KeyValue
+	for _, kv := range keyValues {
+		news, ok := added[kv.Key]
+		if !ok {
+			result = append(result, kv)
+			continue
+		}
+		result = append(result, news...)
+		if consumed[kv.Key] != nil && !kept[kv.Key] {
+			// the value is translated only if all parts are consumed
+			v, _, _ := ParseTagValue(kv.Key, kv.Value)
+			var left []string
+			if v.Name != "" {
+				left = append(left, strconv.Quote(v.Name))
+			}
+			for _, opt := range v.Options {
+				if !consumed[kv.Key][opt.Key] {
+					left = append(left, strconv.Quote(opt.Key))
+				}
+			}
+			if len(left) != 0 {
+				var to []string
+				for _, n := range news {
+					to = append(to, n.Key)
+				}
+				s.warn(field, fmt.Sprintf("field %s: %s of %s can't be translated to %s, keep %s",
+					fieldName, strings.Join(left, ", "), kv.Key, strings.Join(to, ", "), kv.Key))
+				kept[kv.Key] = true
+			}
+		}
+		if kept[kv.Key] {
+			result = append(result, kv)
+		}
+	}
+	return result, true
+}
+
+func (s *tagKeyRenamer) warn(field *ast.Field, msg string) {
+	fmt.Fprintln(migrateOut, newTagDiagnostic(s.fs, field.Tag, "migrate: "+msg).String())
+}
+
+func newTagKeyRename(f *ast.File, fs *token.FileSet, renames, migrations string) (*tagKeyRenamer, error) {
+	r, err := parseKeyRenames(renames)
+	if err != nil {
+		return nil, err
+	}
+	m, err := parseKeyMigrations(migrations)
+	if err != nil {
+		return nil, err
+	}
+	return &tagKeyRenamer{f: f, fs: fs, migrations: append(r, m...)}, nil
+}
@@ -0,0 +1,42 @@
+/*
+ * Copyright 2020 bigpigeon. All rights reserved.
+ * Use of this source code is governed by a MIT style
+ * license that can be found in the LICENSE file.
+ *
+ */
+
+package main
+
+import (
+	"bytes"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"os"
+	"testing"
+)
+
+func TestParseKeyMigrations(t *testing.T) {
+	m, err := parseKeyMigrations("db=gorm.column, query=form")
+	require.NoError(t, err)
+	assert.Equal(t, []keyMigration{{Key: "db", From: "gorm", Option: "column"}, {Key: "query", From: "form"}}, m)
+	_, err = parseKeyMigrations("db=form.column")
+	assert.Error(t, err)
+	_, err = parseKeyRenames("form")
+	assert.Error(t, err)
+}
+
+func TestKeyMigrateWarning(t *testing.T) {
+	resetFlags()
+	initParserMode()
+	var out bytes.Buffer
+	migrateOut = &out
+	defer func() { migrateOut = os.Stderr }()
+	*migrate = "db=gorm.column"
+	defer resetFlags()
+	src := "package main\n\ntype User struct {\n\tID   int    `gorm:\"column:id;primaryKey\"`\n\tName string `gorm:\"type:text\"`\n}\n"
+	res, err := formatSource("rename.go", []byte(src))
+	require.NoError(t, err)
+	assert.Equal(t, "package main\n\ntype User struct {\n\tID   int    `db:\"id\"          gorm:\"column:id;primaryKey\"`\n\tName string `gorm:\"type:text\"`\n}\n", string(res))
+	assert.Equal(t, "rename.go:4:14: migrate: field ID: \"primaryKey\" of gorm can't be translated to db, keep gorm\n"+
+		"rename.go:5:14: migrate: field Name has no gorm option column, keep gorm\n", out.String())
+}
@@ -0,0 +1,255 @@
+/*
+ * Copyright 2020 bigpigeon. All rights reserved.
+ * Use of this source code is governed by a MIT style
+ * license that can be found in the LICENSE file.
+ *
+ */
+
+package main
+
+import (
+	"go/ast"
+	"go/token"
+	"strconv"
+	"strings"
+)
+
+// tagOptionSpec is the legal options of tag key
+type tagOptionSpec struct {
+	options []string
+	// only one of option in the group can be used
+	exclusive [][]string
+	// option key is case insensitive e.g gorm
+	foldCase bool
+}
+
+var tagOptionSpecs = map[string]tagOptionSpec{
+	"json": {options: []string{"omitempty", "omitzero", "string"}},
+	"xml": {
+		options: []string{"attr", "chardata", "cdata", "innerxml", "comment", "any", "omitempty"},
+		exclusive: [][]string{
+			{"attr", "chardata", "cdata", "innerxml", "comment"},
+			{"any", "chardata", "cdata", "innerxml", "comment"},
+		},
+	},
+	"yaml":         {options: []string{"omitempty", "flow", "inline"}},
+	"bson":         {options: []string{"omitempty", "minsize", "truncate", "inline"}},
+	"toml":         {options: []string{"omitempty", "omitzero", "inline", "multiline", "commented"}},
+	"mapstructure": {options: []string{"squash", "remain", "omitempty", "omitzero"}},
+	"gorm": {
+		options: []string{"column", "type", "serializer", "size", "primaryKey", "primary_key", "unique", "default",
+			"precision", "scale", "not null", "autoIncrement", "autoIncrementIncrement", "embedded",
+			"embeddedPrefix", "autoCreateTime", "autoUpdateTime", "index", "uniqueIndex", "check", "<-", "->", "-",
+			"comment", "foreignKey", "references", "polymorphic", "polymorphicValue", "polymorphicType",
+			"polymorphicId", "many2many", "joinForeignKey", "joinReferences", "constraint", "null", "unsigned",
+			"auto_increment", "unique_index"},
+		foldCase: true,
+	},
+}
+
+type tagValidator struct {
+	f           *ast.File
+	fs          *token.FileSet
+	fix         bool
+	fields      []*ast.Field
+	diagnostics []tagDiagnostic
+}
+
+func (s *tagValidator) Scan() error {
+	ast.Walk(s, s.f)
+	return nil
+}
+
+// Execute applies the suggested fixes if fix is enabled
+func (s *tagValidator) Execute() error {
+	if !s.fix {
+		return nil
+	}
+	for _, field := range s.fields {
+		// removed by policy
+		if field.Tag == nil {
+			continue
+		}
+		if _, fixed := validateFieldTag(s.fs, field); fixed != "" {
+			field.Tag.Value = fixed
+			field.Tag.ValuePos = 0
+		}
+	}
+	return nil
+}
+
+func (s *tagValidator) Visit(node ast.Node) ast.Visitor {
+	cmap := ast.NewCommentMap(s.fs, node, s.f.Comments)
+	visit := newTopVisit(cmap, s.executor)
+	return visit.Visit(node)
+}
+
+func (s *tagValidator) executor(name string, comments []*ast.CommentGroup, n *ast.StructType) {
+	if n.Fields != nil {
+		for _, field := range n.Fields.List {
+			if fieldFilter(getFieldName(field)) && field.Tag != nil {
+				diagnostics, _ := validateFieldTag(s.fs, field)
+				if len(diagnostics) != 0 {
+					s.fields = append(s.fields, field)
+					s.diagnostics = append(s.diagnostics, diagnostics...)
+				}
+			}
+		}
+	}
+}
+
+// validateFieldTag returns the problems of options in field tag,
+// and the fixed tag literal if all unknown options have suggestion
+func validateFieldTag(fs *token.FileSet, field *ast.Field) ([]tagDiagnostic, string) {
+	quote, keyValues, err := ParseTag(field.Tag.Value)
+	if err != nil {
+		// reported by tag doctor
+		return nil, ""
+	}
+	var diagnostics []tagDiagnostic
+	changed := false
+	for i, kv := range keyValues {
+		spec, ok := tagOptionSpecs[kv.Key]
+		if !ok {
+			continue
+		}
+		v, g, err := ParseTagValue(kv.Key, kv.Value)
+		if err != nil {
+			diag := newTagDiagnostic(fs, field.Tag, kv.Key+": "+err.Error())
+			diag.Error = true
+			diagnostics = append(diagnostics, diag)
+			continue
+		}
+		used := map[string]bool{}
+		for j, opt := range v.Options {
+			name := opt.Key
+			if spec.foldCase {
+				name = strings.ToLower(name)
+			}
+			if name == "" {
+				continue
+			}
+			if used[name] {
+				diagnostics = append(diagnostics, newTagDiagnostic(fs, field.Tag,
+					kv.Key+": repeated option "+strconv.Quote(opt.Key)))
+				continue
+			}
+			used[name] = true
+			if spec.known(opt.Key) {
+				continue
+			}
+			msg := kv.Key + ": unknown option " + strconv.Quote(opt.Key)
+			suggest := spec.suggest(opt.Key)
+			if suggest != "" {
+				msg += ", did you mean " + strconv.Quote(suggest) + "?"
+				v.Options[j].Key = suggest
+				changed = true
+			}
+			diag := newTagDiagnostic(fs, field.Tag, msg)
+			if suggest != "" {
+				diag.FixTitle = "Replace " + strconv.Quote(opt.Key) + " with " + strconv.Quote(suggest)
+			}
+			diagnostics = append(diagnostics, diag)
+		}
+		for _, group := range spec.exclusive {
+			var conflicts []string
+			for _, o := range group {
+				if used[o] {
+					conflicts = append(conflicts, strconv.Quote(o))
+				}
+			}
+			if len(conflicts) > 1 {
+				diag := newTagDiagnostic(fs, field.Tag, kv.Key+": conflicting options "+strings.Join(conflicts, " and "))
+				diag.Error = true
+				diagnostics = append(diagnostics, diag)
+			}
+		}
+		keyValues[i].Value = g.Print(v)
+	}
+	if !changed {
+		return diagnostics, ""
+	}
+	var keyValuesRaw []string
+	for _, kv := range keyValues {
+		keyValuesRaw = append(keyValuesRaw, kv.String())
+	}
+	fixed := quote + strings.Join(keyValuesRaw, " ") + quote
+	for i := range diagnostics {
+		if diagnostics[i].FixTitle != "" {
+			diagnostics[i].Fix = fixed
+		}
+	}
+	return diagnostics, fixed
+}
+
+func (spec tagOptionSpec) known(key string) bool {
+	for _, o := range spec.options {
+		if o == key || (spec.foldCase && strings.EqualFold(o, key)) {
+			return true
+		}
+	}
+	return false
+}
+
+// suggest returns the most similar legal option, empty if there is no obvious one
+func (spec tagOptionSpec) suggest(key string) string {
+	best, bestDist, unique := "", 3, false
+	for _, o := range spec.options {
+		a, b := o, key
+		if spec.foldCase {
+			a, b = strings.ToLower(a), strings.ToLower(b)
+		}
+		d := editDistance(a, b)
+		if d*2 >= len(o) {
+			continue
+		}
+		if d < bestDist {
+			best, bestDist, unique = o, d, true
+		} else if d == bestDist {
+			unique = false
+		}
+	}
+	if !unique {
+		return ""
+	}
+	return best
+}
+
+// editDistance returns the edit distance of a and b, swapping two adjacent characters is one edit
+func editDistance(a, b string) int {
+	prePre := make([]int, len(b)+1)
+	pre := make([]int, len(b)+1)
+	cur := make([]int, len(b)+1)
+	for j := range pre {
+		pre[j] = j
+	}
+	for i := 1; i <= len(a); i++ {
+		cur[0] = i
+		for j := 1; j <= len(b); j++ {
+			cost := 1
+			if a[i-1] == b[j-1] {
+				cost = 0
+			}
+			cur[j] = min3(pre[j]+1, cur[j-1]+1, pre[j-1]+cost)
+			if i > 1 && j > 1 && a[i-1] == b[j-2] && a[i-2] == b[j-1] && prePre[j-2]+1 < cur[j] {
+				cur[j] = prePre[j-2] + 1
+			}
+		}
+		prePre, pre, cur = pre, cur, prePre
+	}
+	return pre[len(b)]
+}
+
+func min3(a, b, c int) int {
+	if b < a {
+		a = b
+	}
+	if c < a {
+		a = c
+	}
+	return a
+}
+
+func newTagValidate(f *ast.File, fs *token.FileSet, fix bool) *tagValidator {
+	return &tagValidator{f: f, fs: fs, fix: fix}
+}
@@ -0,0 +1,154 @@
+/*
+ * Copyright 2020 bigpigeon. All rights reserved.
+ * Use of this source code is governed by a MIT style
+ * license that can be found in the LICENSE file.
+ *
+ */
+
+package main
+
+import (
+	"errors"
+	"go/ast"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+// parseLineRange parses the -lines arg e.g 40:75 or 40
+func parseLineRange(s string) (lineRange, error) {
+	pos := strings.SplitN(s, ":", 2)
+	start, err := strconv.Atoi(strings.TrimSpace(pos[0]))
+	if err != nil || start <= 0 {
+		return lineRange{}, errors.New("lines format error please check 'lines' arg")
+	}
+	end := start
+	if len(pos) == 2 {
+		end, err = strconv.Atoi(strings.TrimSpace(pos[1]))
+		if err != nil || end < start {
+			return lineRange{}, errors.New("lines format error please check 'lines' arg")
+		}
+	}
+	return lineRange{start, end}, nil
+}
+
+// cursorPosition is the -at target e.g file.go:123:5
+type cursorPosition struct {
+	Filename string
+	Line     int
+	Column   int
+}
+
+func parseCursorPosition(s string) (cursorPosition, error) {
+	// split from right, the file name may contain ':'
+	var nums []int
+	for i := 0; i < 2; i++ {
+		idx := strings.LastIndex(s, ":")
+		if idx == -1 {
+			return cursorPosition{}, errors.New("at format error please check 'at' arg")
+		}
+		n, err := strconv.Atoi(s[idx+1:])
+		if err != nil || n <= 0 {
+			return cursorPosition{}, errors.New("at format error please check 'at' arg")
+		}
+		nums = append(nums, n)
+		s = s[:idx]
+	}
+	if s == "" {
+		return cursorPosition{}, errors.New("at format error please check 'at' arg")
+	}
+	return cursorPosition{Filename: s, Line: nums[1], Column: nums[0]}, nil
+}
+
+// match reports whether the filename is the target file
+func (c cursorPosition) match(filename string) bool {
+	a, err := filepath.Abs(c.Filename)
+	if err != nil {
+		return false
+	}
+	b, err := filepath.Abs(filename)
+	if err != nil {
+		return false
+	}
+	return a == b
+}
+
+func (c cursorPosition) in(n ast.Node) bool {
+	start := fileSet.Position(n.Pos())
+	end := fileSet.Position(n.End())
+	if c.Line < start.Line || (c.Line == start.Line && c.Column < start.Column) {
+		return false
+	}
+	if c.Line > end.Line || (c.Line == end.Line && c.Column >= end.Column) {
+		return false
+	}
+	return true
+}
+
+// positionSelect returns a structRangeSelect that select the innermost struct containing the position
+func positionSelect(c cursorPosition) func(n *ast.StructType) bool {
+	return func(n *ast.StructType) bool {
+		if !c.in(n) {
+			return false
+		}
+		inner := false
+		ast.Inspect(n, func(node ast.Node) bool {
+			if inner {
+				return false
+			}
+			if st, ok := node.(*ast.StructType); ok && st != n && c.in(st) {
+				inner = true
+			}
+			return !inner
+		})
+		return !inner
+	}
+}
+
+func selectNone(n *ast.StructType) bool {
+	return false
+}
+
+// allSelect combines multiple struct selectors, the struct must satisfy all of them
+func allSelect(selects []func(n *ast.StructType) bool) func(n *ast.StructType) bool {
+	return func(n *ast.StructType) bool {
+		for _, sel := range selects {
+			if !sel(n) {
+				return false
+			}
+		}
+		return true
+	}
+}
+
+// targetSelects returns the struct selectors from -diff-base, -lines and -at
+func targetSelects(filename string, stdin bool) ([]func(n *ast.StructType) bool, error) {
+	var selects []func(n *ast.StructType) bool
+	if *diffBase != "" && !stdin {
+		ranges, err := gitChangedLines(filename, *diffBase, false)
+		if err != nil {
+			return nil, err
+		}
+		selects = append(selects, lineRangeSelect(ranges))
+	}
+	if *lines != "" {
+		r, err := parseLineRange(*lines)
+		if err != nil {
+			return nil, err
+		}
+		selects = append(selects, lineRangeSelect([]lineRange{r}))
+	}
+	if *at != "" {
+		c, err := parseCursorPosition(*at)
+		if err != nil {
+			return nil, err
+		}
+		// stdin content is treated as the target file
+		if stdin || c.match(filename) {
+			selects = append(selects, positionSelect(c))
+		} else {
+			selects = append(selects, selectNone)
+		}
+	}
+	return selects, nil
+}
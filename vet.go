@@ -0,0 +1,198 @@
+/*
+ * Copyright 2020 bigpigeon. All rights reserved.
+ * Use of this source code is governed by a MIT style
+ * license that can be found in the LICENSE file.
+ *
+ */
+
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"go/ast"
+	"go/build"
+	"go/importer"
+	"go/parser"
+	"go/token"
+	"go/types"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// typedPackage is a parsed and type checked package, the type errors are ignored
+type typedPackage struct {
+	Dir   string
+	Files []*ast.File
+	Info  *types.Info
+	Types *types.Package
+}
+
+// packageDirs returns the directories of paths, path end with /... means all sub directories
+func packageDirs(paths []string) ([]string, error) {
+	var dirs []string
+	seen := map[string]bool{}
+	add := func(dir string) {
+		if !seen[dir] {
+			seen[dir] = true
+			dirs = append(dirs, dir)
+		}
+	}
+	for _, p := range paths {
+		if p == "..." || strings.HasSuffix(p, "/...") {
+			root := expandPath(p)
+			err := filepath.Walk(root, func(path string, f os.FileInfo, err error) error {
+				if err != nil {
+					return err
+				}
+				if f.IsDir() {
+					name := f.Name()
+					if path != root && (strings.HasPrefix(name, ".") || strings.HasPrefix(name, "_") ||
+						name == "vendor" || name == "testdata") {
+						return filepath.SkipDir
+					}
+					return nil
+				}
+				if isGoFile(f) {
+					add(filepath.Dir(path))
+				}
+				return nil
+			})
+			if err != nil {
+				return nil, err
+			}
+			continue
+		}
+		f, err := os.Stat(p)
+		if err != nil {
+			return nil, err
+		}
+		if f.IsDir() {
+			add(p)
+		} else {
+			add(filepath.Dir(p))
+		}
+	}
+	return dirs, nil
+}
+
+// checkPackage type checks the go files, imported packages are loaded from source
+func checkPackage(fs *token.FileSet, dir string, files []*ast.File) *typedPackage {
+	conf := types.Config{
+		Importer: importer.ForCompiler(fs, "source", nil),
+		// report as many as possible, the type errors are not our business
+		Error: func(err error) {},
+	}
+	info := &types.Info{
+		Types: map[ast.Expr]types.TypeAndValue{},
+		Defs:  map[*ast.Ident]types.Object{},
+		Uses:  map[*ast.Ident]types.Object{},
+	}
+	name := ""
+	if len(files) != 0 {
+		name = files[0].Name.Name
+	}
+	pkg, _ := conf.Check(name, fs, files, info)
+	return &typedPackage{Dir: dir, Files: files, Info: info, Types: pkg}
+}
+
+// loadPackage parses and type checks the package in directory, test files are excluded
+func loadPackage(fs *token.FileSet, dir string) (*typedPackage, error) {
+	bp, err := build.ImportDir(dir, 0)
+	if err != nil {
+		if _, ok := err.(*build.NoGoError); ok {
+			return nil, nil
+		}
+		return nil, err
+	}
+	var files []*ast.File
+	for _, name := range bp.GoFiles {
+		f, err := parser.ParseFile(fs, filepath.Join(dir, name), nil, parser.ParseComments)
+		if err != nil {
+			return nil, err
+		}
+		files = append(files, f)
+	}
+	return checkPackage(fs, dir, files), nil
+}
+
+// namedStructs returns the struct types declared at package level in declaration order
+func (p *typedPackage) namedStructs() []*types.TypeName {
+	var result []*types.TypeName
+	for _, file := range p.Files {
+		for _, decl := range file.Decls {
+			gen, ok := decl.(*ast.GenDecl)
+			if !ok || gen.Tok != token.TYPE {
+				continue
+			}
+			for _, spec := range gen.Specs {
+				ts := spec.(*ast.TypeSpec)
+				obj, ok := p.Info.Defs[ts.Name].(*types.TypeName)
+				if !ok {
+					continue
+				}
+				if _, ok := obj.Type().Underlying().(*types.Struct); ok {
+					result = append(result, obj)
+				}
+			}
+		}
+	}
+	return result
+}
+
+// vetPackage reports the serialized name collisions of all struct in package
+func vetPackage(fs *token.FileSet, pkg *typedPackage, keys []string, out io.Writer) int {
+	count := 0
+	for _, obj := range pkg.namedStructs() {
+		st := obj.Type().Underlying().(*types.Struct)
+		for _, key := range keys {
+			for _, issue := range wireEncoders[key].check(obj.Name(), obj.Pos(), st) {
+				fmt.Fprintf(out, "%s: %s\n", fs.Position(issue.Pos), issue.Msg)
+				count++
+			}
+		}
+	}
+	return count
+}
+
+func vetMain(args []string) error {
+	flags := flag.NewFlagSet("vet", flag.ContinueOnError)
+	keysArg := flags.String("keys", "json,yaml,bson", "the serialization keys to check e.g json,yaml,bson,xml")
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+	var keys []string
+	for _, key := range strings.Split(*keysArg, ",") {
+		key = strings.TrimSpace(key)
+		if key == "" {
+			continue
+		}
+		if _, ok := wireEncoders[key]; !ok {
+			return errors.New("unsupported key " + key + " please check 'keys' arg")
+		}
+		keys = append(keys, key)
+	}
+	paths := flags.Args()
+	if len(paths) == 0 {
+		paths = []string{"."}
+	}
+	dirs, err := packageDirs(paths)
+	if err != nil {
+		return err
+	}
+	fs := token.NewFileSet()
+	for _, dir := range dirs {
+		pkg, err := loadPackage(fs, dir)
+		if err != nil {
+			report(err)
+			continue
+		}
+		if pkg != nil && vetPackage(fs, pkg, keys, os.Stdout) != 0 && exitCode == 0 {
+			exitCode = 1
+		}
+	}
+	return nil
+}
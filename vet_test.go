@@ -0,0 +1,67 @@
+/*
+ * Copyright 2020 bigpigeon. All rights reserved.
+ * Use of this source code is governed by a MIT style
+ * license that can be found in the LICENSE file.
+ *
+ */
+
+package main
+
+import (
+	"bytes"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go/token"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestVetPackage(t *testing.T) {
+	dir, err := ioutil.TempDir("", "tagfmt_vet")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+	src := "package api\n\n" +
+		"type Base struct {\n\tID   string `json:\"id\" yaml:\"id\"`\n\tName string\n}\n\n" +
+		"type Other struct {\n\tID string `json:\"id\"`\n}\n\n" +
+		"type User struct {\n\tBase\n\t*Other\n\tName string `yaml:\"name\"`\n\tMeta `yaml:\",inline\"`\n\tmeta\n}\n\n" +
+		"type Meta struct {\n\tName string\n\tIgnore string `json:\"-\" yaml:\"-\"`\n}\n\n" +
+		"type meta struct {\n\tIgnore string `json:\"ignore\"`\n}\n\n" +
+		"type Order struct {\n\tMeta\n\tIgnore string `json:\"ignore\"`\n}\n"
+	require.NoError(t, ioutil.WriteFile(filepath.Join(dir, "api.go"), []byte(src), 0644))
+
+	fs := token.NewFileSet()
+	pkg, err := loadPackage(fs, dir)
+	require.NoError(t, err)
+	var out bytes.Buffer
+	assert.Equal(t, 4, vetPackage(fs, pkg, []string{"json", "yaml", "bson"}, &out))
+	assert.Equal(t, []string{
+		`api.go:12:6: json: field Base.Name of User is shadowed by Name with the same name "Name"`,
+		`api.go:12:6: json: field Meta.Name of User is shadowed by Name with the same name "Name"`,
+		`api.go:12:6: json: fields Base.ID and Other.ID of User have the same name "id" at the same depth, all of them are ignored`,
+		`api.go:12:6: yaml: fields Name and Meta.Name of User have the same key "name"`,
+	}, strings.Split(strings.TrimSpace(strings.Replace(out.String(), dir+string(os.PathSeparator), "", -1)), "\n"))
+}
+
+func TestVetPackageSameEmbedded(t *testing.T) {
+	dir, err := ioutil.TempDir("", "tagfmt_vet")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+	src := "package api\n\n" +
+		"type Common struct {\n\tCode string `json:\"code\"`\n}\n\n" +
+		"type Request struct {\n\tCommon\n}\n\n" +
+		"type Response struct {\n\tCommon\n}\n\n" +
+		"type Exchange struct {\n\tRequest\n\tResponse\n}\n"
+	require.NoError(t, ioutil.WriteFile(filepath.Join(dir, "api.go"), []byte(src), 0644))
+
+	fs := token.NewFileSet()
+	pkg, err := loadPackage(fs, dir)
+	require.NoError(t, err)
+	var out bytes.Buffer
+	assert.Equal(t, 1, vetPackage(fs, pkg, []string{"json"}, &out))
+	assert.Equal(t,
+		`api.go:15:6: json: fields Request.Common.Code and Response.Common.Code of Exchange have the same name "code" at the same depth, all of them are ignored`,
+		strings.TrimSpace(strings.Replace(out.String(), dir+string(os.PathSeparator), "", -1)))
+}
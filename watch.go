@@ -0,0 +1,165 @@
+/*
+ * Copyright 2020 bigpigeon. All rights reserved.
+ * Use of this source code is governed by a MIT style
+ * license that can be found in the LICENSE file.
+ *
+ */
+
+package main
+
+import (
+	"os"
+	"os/signal"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+const (
+	watchInterval = 500 * time.Millisecond
+	// wait the file stop changing before format it, editor may save file multiple times
+	watchDebounce = 300 * time.Millisecond
+)
+
+type watchState struct {
+	modTime time.Time
+	size    int64
+}
+
+type watcher struct {
+	paths   []string
+	ignore  []string
+	files   map[string]watchState
+	pending map[string]time.Time
+	process func(path string) error
+}
+
+func newWatcher(paths []string, ignore []string, process func(path string) error) *watcher {
+	return &watcher{
+		paths:   paths,
+		ignore:  ignore,
+		pending: map[string]time.Time{},
+		process: process,
+	}
+}
+
+// ignored reports whether the path or any of its directory matches the ignore patterns
+func (w *watcher) ignored(root, path string) bool {
+	rel, err := filepath.Rel(root, path)
+	if err != nil {
+		rel = path
+	}
+	rel = filepath.ToSlash(rel)
+	for _, pattern := range w.ignore {
+		if ok, _ := filepath.Match(pattern, rel); ok {
+			return true
+		}
+		for _, elem := range strings.Split(rel, "/") {
+			if ok, _ := filepath.Match(pattern, elem); ok {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+func (w *watcher) scan() map[string]watchState {
+	files := map[string]watchState{}
+	for _, root := range w.paths {
+		filepath.Walk(root, func(path string, f os.FileInfo, err error) error {
+			if err != nil {
+				return nil
+			}
+			if path != root && (strings.HasPrefix(f.Name(), ".") || w.ignored(root, path)) {
+				if f.IsDir() {
+					return filepath.SkipDir
+				}
+				return nil
+			}
+			if isGoFile(f) {
+				files[path] = watchState{f.ModTime(), f.Size()}
+			}
+			return nil
+		})
+	}
+	return files
+}
+
+// poll detects the modified files and process the files that stop changing for a while,
+// the first poll only records the files state
+func (w *watcher) poll(now time.Time) {
+	files := w.scan()
+	if w.files == nil {
+		w.files = files
+		return
+	}
+	for path, state := range files {
+		if old, ok := w.files[path]; !ok || old != state {
+			w.pending[path] = now
+		}
+	}
+	w.files = files
+	for path, changed := range w.pending {
+		if now.Sub(changed) < watchDebounce {
+			continue
+		}
+		delete(w.pending, path)
+		// the config and lookup files may be edited
+		configCache = map[string]*tagConfig{}
+		lookupTables = map[string]map[string]string{}
+		if err := w.process(path); err != nil && !os.IsNotExist(err) {
+			report(err)
+		}
+		// record the state after process, don't re-trigger on our own writes
+		if f, err := os.Stat(path); err == nil {
+			w.files[path] = watchState{f.ModTime(), f.Size()}
+		}
+	}
+}
+
+func (w *watcher) run(stop <-chan os.Signal) {
+	ticker := time.NewTicker(watchInterval)
+	defer ticker.Stop()
+	w.poll(time.Now())
+	for {
+		select {
+		case <-stop:
+			return
+		case now := <-ticker.C:
+			w.poll(now)
+		}
+	}
+}
+
+func watchMain(paths []string) {
+	if len(paths) == 0 {
+		paths = []string{"."}
+	}
+	for i := range paths {
+		paths[i] = expandPath(paths[i])
+	}
+	var ignore []string
+	for _, pattern := range strings.Split(*watchIgnore, "|") {
+		if pattern = strings.TrimSpace(pattern); pattern != "" {
+			ignore = append(ignore, pattern)
+		}
+	}
+	*write = true
+	w := newWatcher(paths, ignore, func(path string) error {
+		return processFile(path, nil, os.Stdout, false)
+	})
+	stop := make(chan os.Signal, 1)
+	signal.Notify(stop, os.Interrupt)
+	w.run(stop)
+}
+
+// expandPath converts the go package pattern e.g ./... to the directory
+func expandPath(path string) string {
+	if path == "..." {
+		return "."
+	}
+	if strings.HasSuffix(path, "/...") {
+		return strings.TrimSuffix(path, "/...")
+	}
+	return path
+}
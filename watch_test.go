@@ -0,0 +1,62 @@
+/*
+ * Copyright 2020 bigpigeon. All rights reserved.
+ * Use of this source code is governed by a MIT style
+ * license that can be found in the LICENSE file.
+ *
+ */
+
+package main
+
+import (
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestWatcher(t *testing.T) {
+	resetFlags()
+	initParserMode()
+	*write = true
+	defer resetFlags()
+	dir, err := ioutil.TempDir("", "tagfmt_watch")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+	require.NoError(t, os.Mkdir(filepath.Join(dir, "vendor"), 0755))
+	src := []byte("package api\n\ntype User struct {\n\tID   string `json:\"id\" yaml:\"id\"`\n\tName string `json:\"name\" yaml:\"name\"`\n}\n")
+	filename := filepath.Join(dir, "api.go")
+	vendorFile := filepath.Join(dir, "vendor", "api.go")
+	require.NoError(t, ioutil.WriteFile(filename, []byte("package api\n"), 0644))
+	require.NoError(t, ioutil.WriteFile(vendorFile, []byte("package api\n"), 0644))
+
+	var processed []string
+	w := newWatcher([]string{dir}, []string{"vendor"}, func(path string) error {
+		processed = append(processed, path)
+		return processFile(path, nil, ioutil.Discard, false)
+	})
+	now := time.Now()
+	w.poll(now)
+	assert.Empty(t, processed)
+
+	modTime := now.Add(time.Second)
+	for _, path := range []string{filename, vendorFile} {
+		require.NoError(t, ioutil.WriteFile(path, src, 0644))
+		require.NoError(t, os.Chtimes(path, modTime, modTime))
+	}
+	w.poll(now.Add(watchInterval))
+	assert.Empty(t, processed, "wait the file stop changing")
+
+	w.poll(now.Add(watchInterval * 2))
+	assert.Equal(t, []string{filename}, processed)
+	data, err := ioutil.ReadFile(filename)
+	require.NoError(t, err)
+	assert.Equal(t, "package api\n\ntype User struct {\n\tID   string `json:\"id\"   yaml:\"id\"`\n\tName string `json:\"name\" yaml:\"name\"`\n}\n", string(data))
+
+	// the rewrite by watcher doesn't trigger again
+	w.poll(now.Add(watchInterval * 3))
+	w.poll(now.Add(watchInterval * 4))
+	assert.Equal(t, []string{filename}, processed)
+}
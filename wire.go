@@ -0,0 +1,217 @@
+/*
+ * Copyright 2020 bigpigeon. All rights reserved.
+ * Use of this source code is governed by a MIT style
+ * license that can be found in the LICENSE file.
+ *
+ */
+
+package main
+
+import (
+	"go/token"
+	"go/types"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// wireField is a field in the serialized struct, Path is the go field names from the root struct
+type wireField struct {
+	Name   string
+	Tagged bool
+	Depth  int
+	Path   []string
+	Var    *types.Var
+	Value  *TagValue // parsed tag value, nil if the field has no tag of the key
+}
+
+func (f wireField) path() string {
+	return strings.Join(f.Path, ".")
+}
+
+// wireEncoder is the rules of serialization library how to name the struct fields
+type wireEncoder struct {
+	key string
+	// the implicit name of field without tag name
+	defaultName func(field string) string
+	// embedded struct is only promoted with ',inline' option and the duplicated names is error,
+	// otherwise use encoding/json rules, untagged embedded struct is promoted and the shallower field hides deeper field
+	inlineOnly bool
+}
+
+var wireEncoders = map[string]wireEncoder{
+	"json": {key: "json", defaultName: func(s string) string { return s }},
+	"xml":  {key: "xml", defaultName: func(s string) string { return s }},
+	"yaml": {key: "yaml", defaultName: strings.ToLower, inlineOnly: true},
+	"bson": {key: "bson", defaultName: strings.ToLower, inlineOnly: true},
+}
+
+// wireIssue is a problem of serialized names
+type wireIssue struct {
+	Pos token.Pos
+	Msg string
+}
+
+func derefStruct(t types.Type) (*types.Struct, bool) {
+	if ptr, ok := t.(*types.Pointer); ok {
+		t = ptr.Elem()
+	}
+	st, ok := t.Underlying().(*types.Struct)
+	return st, ok
+}
+
+// fields returns all fields with serialized name in struct, embedded fields are expanded
+func (e wireEncoder) fields(st *types.Struct) []wireField {
+	type queued struct {
+		st   *types.Struct
+		path []string
+	}
+	var result []wireField
+	current := []queued{{st: st}}
+	// the struct reached at a shallower depth is skipped, but the same struct reached by
+	// different paths at the same depth is expanded each time as encoding/json does
+	visited := map[*types.Struct]bool{}
+	for depth := 0; len(current) != 0; depth++ {
+		var next []queued
+		for _, q := range current {
+			if visited[q.st] {
+				continue
+			}
+			for i := 0; i < q.st.NumFields(); i++ {
+				f := q.st.Field(i)
+				path := append(append([]string{}, q.path...), f.Name())
+				tag, hasTag := reflect.StructTag(q.st.Tag(i)).Lookup(e.key)
+				if tag == "-" {
+					continue
+				}
+				var value *TagValue
+				if hasTag {
+					value, _ = commaNamedGrammar.Parse(tag)
+				}
+				name := ""
+				inline := false
+				if value != nil {
+					name = value.Name
+					_, inline = value.Option("inline")
+				}
+				embedded, isStruct := derefStruct(f.Type())
+				if e.inlineOnly {
+					if inline {
+						if isStruct {
+							next = append(next, queued{embedded, path})
+						}
+						continue
+					}
+					if !f.Exported() {
+						continue
+					}
+				} else {
+					if f.Anonymous() {
+						if !f.Exported() && !isStruct {
+							continue
+						}
+					} else if !f.Exported() {
+						continue
+					}
+					if name == "" && f.Anonymous() && isStruct {
+						next = append(next, queued{embedded, path})
+						continue
+					}
+				}
+				field := wireField{Name: name, Tagged: name != "", Depth: depth, Path: path, Var: f, Value: value}
+				if name == "" {
+					field.Name = e.defaultName(f.Name())
+				}
+				result = append(result, field)
+			}
+		}
+		for _, q := range current {
+			visited[q.st] = true
+		}
+		current = next
+	}
+	return result
+}
+
+// dominantFields sorts the fields with the same name by encoding/json rules, and returns the fields at the top
+func dominantFields(group []wireField) []wireField {
+	sort.SliceStable(group, func(i, j int) bool {
+		if group[i].Depth != group[j].Depth {
+			return group[i].Depth < group[j].Depth
+		}
+		return group[i].Tagged && !group[j].Tagged
+	})
+	var top []wireField
+	for _, f := range group {
+		if f.Depth == group[0].Depth && f.Tagged == group[0].Tagged {
+			top = append(top, f)
+		}
+	}
+	return top
+}
+
+// visible returns the fields that are really serialized by name, the conflicting fields are dropped
+func (e wireEncoder) visible(fields []wireField) map[string]wireField {
+	groups := map[string][]wireField{}
+	for _, f := range fields {
+		groups[f.Name] = append(groups[f.Name], f)
+	}
+	result := map[string]wireField{}
+	for name, group := range groups {
+		if e.inlineOnly {
+			// the decoder rejects it, keep the first one to compare
+			result[name] = group[0]
+			continue
+		}
+		if top := dominantFields(group); len(top) == 1 {
+			result[name] = top[0]
+		}
+	}
+	return result
+}
+
+// check returns the collisions and shadowed fields of struct
+func (e wireEncoder) check(name string, pos token.Pos, st *types.Struct) []wireIssue {
+	fields := e.fields(st)
+	groups := map[string][]wireField{}
+	var names []string
+	for _, f := range fields {
+		if groups[f.Name] == nil {
+			names = append(names, f.Name)
+		}
+		groups[f.Name] = append(groups[f.Name], f)
+	}
+	sort.Strings(names)
+	var issues []wireIssue
+	for _, n := range names {
+		group := groups[n]
+		if len(group) < 2 {
+			continue
+		}
+		if e.inlineOnly {
+			var paths []string
+			for _, f := range group {
+				paths = append(paths, f.path())
+			}
+			issues = append(issues, wireIssue{pos, e.key + ": fields " + strings.Join(paths, " and ") + " of " + name +
+				" have the same key " + strconv.Quote(n)})
+			continue
+		}
+		top := dominantFields(group)
+		if len(top) > 1 {
+			var paths []string
+			for _, f := range top {
+				paths = append(paths, f.path())
+			}
+			issues = append(issues, wireIssue{pos, e.key + ": fields " + strings.Join(paths, " and ") + " of " + name +
+				" have the same name " + strconv.Quote(n) + " at the same depth, all of them are ignored"})
+			continue
+		}
+		for _, f := range group[1:] {
+			issues = append(issues, wireIssue{pos, e.key + ": field " + f.path() + " of " + name +
+				" is shadowed by " + group[0].path() + " with the same name " + strconv.Quote(n)})
+		}
+	}
+	return issues
+}